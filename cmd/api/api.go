@@ -12,13 +12,17 @@ import (
 	"github.com/minhnghia2k3/GOssage/internal"
 	"github.com/minhnghia2k3/GOssage/internal/auth"
 	"github.com/minhnghia2k3/GOssage/internal/env"
+	"github.com/minhnghia2k3/GOssage/internal/mailer"
+	"github.com/minhnghia2k3/GOssage/internal/objectstore"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"github.com/minhnghia2k3/GOssage/internal/store/cache"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -29,7 +33,10 @@ type application struct {
 	cacheStorage  *cache.Storage
 	logger        *zap.SugaredLogger
 	mailer        internal.Client
+	templates     *mailer.Templates
+	objects       objectstore.Store
 	authenticator auth.Authenticator
+	wg            sync.WaitGroup
 }
 
 type config struct {
@@ -42,6 +49,48 @@ type config struct {
 	auth        authConfig
 	redisConfig redisConfig
 	limiter     limiterConfig
+	// trustedProxies may set the client IP address with X-Forwarded-For or X-Real-IP
+	trustedProxies []netip.Prefix
+	janitor        janitorConfig
+	trash          trashConfig
+	scheduler      schedulerConfig
+	comments       commentConfig
+	uploads        uploadsConfig
+}
+
+type commentConfig struct {
+	// maxDepth is the deepest level of replies, top level comments are at depth 0
+	maxDepth int
+}
+
+type janitorConfig struct {
+	interval    time.Duration
+	gracePeriod time.Duration
+}
+
+type trashConfig struct {
+	interval time.Duration
+	// retention is how long deleted posts and users can be restored before they are purged
+	retention time.Duration
+}
+
+type schedulerConfig struct {
+	// interval is how often due scheduled posts are published
+	interval time.Duration
+}
+
+type uploadsConfig struct {
+	// driver is local or s3
+	driver string
+	// dir stores files of the local driver, served at publicURL
+	dir       string
+	publicURL string
+	s3        objectstore.S3Config
+	// maxBytes is the largest uploaded file, requests with JSON bodies are limited separately
+	maxBytes int64
+	// orphanTTL is how long an upload can stay unattached to any post before it is deleted
+	orphanTTL  time.Duration
+	gcInterval time.Duration
 }
 
 type limiterConfig struct {
@@ -58,26 +107,52 @@ type redisConfig struct {
 }
 
 type authConfig struct {
-	token tokenConfig
+	token   tokenConfig
+	lockout lockoutConfig
+}
+
+type lockoutConfig struct {
+	maxAttempts      int
+	maxAttemptsPerIP int
+	window           time.Duration
+	duration         time.Duration
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	keyFiles   []string
+	exp        time.Duration
+	refreshExp time.Duration
+	iss        string
 }
 
 type mailConfig struct {
-	exp       time.Duration
-	fromEmail string
-	dialer    dialer
+	driver         string
+	fileDir        string
+	exp            time.Duration
+	resetExp       time.Duration
+	resendCooldown time.Duration
+	fromEmail      string
+	dialer         dialer
+	outbox         outboxConfig
+}
+
+type outboxConfig struct {
+	workers      int
+	pollInterval time.Duration
+	batchSize    int
+	lease        time.Duration
+	maxAttempts  int
+	backoffBase  time.Duration
+	backoffMax   time.Duration
 }
 
 type dialer struct {
-	host     string
-	port     int
-	username string
-	password string
+	host        string
+	port        int
+	username    string
+	password    string
+	implicitTLS bool
 }
 
 type dbConfig struct {
@@ -92,26 +167,34 @@ func (app *application) mount() http.Handler {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{env.GetString("CORS_ALLOW_ORIGIN", "http://localhost:3000")},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
 	// Middleware stack
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(app.realIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(app.rateLimiter)
 
 	// Define routes
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 
 		r.Get("/healthcheck", app.healthCheckHandler)
 		r.Get("/debug/vars", expvar.Handler().ServeHTTP)
 
+		// Sent emails contain activation and password reset tokens, the in-memory
+		// driver is meant for development and end-to-end tests only
+		if app.config.mail.driver == "memory" {
+			r.Get("/debug/mails", app.debugMailsHandler)
+		}
+
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
@@ -121,20 +204,64 @@ func (app *application) mount() http.Handler {
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.getPostMiddleware)
-				r.Get("/", app.getPostHandler)
 				r.Patch("/", app.checkPostOwnerShip("moderator", app.updatePostHandler))
 				r.Delete("/", app.checkPostOwnerShip("admin", app.deletePostHandler))
+				r.Get("/revisions", app.checkPostOwnerShip("moderator", app.getPostRevisionsHandler))
+				r.Get("/revisions/diff", app.checkPostOwnerShip("moderator", app.getPostDiffHandler))
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.checkPostVisibility)
+					r.Get("/", app.getPostHandler)
+					r.Get("/comments", app.getCommentsHandler)
+					r.Post("/comments", app.createCommentHandler)
+					r.Put("/reactions", app.reactToPostHandler)
+					r.Delete("/reactions", app.removeReactionHandler)
+					r.Put("/bookmark", app.bookmarkPostHandler)
+					r.Delete("/bookmark", app.unbookmarkPostHandler)
+				})
 			})
 		})
 
+		r.With(app.AuthMiddleware).Get("/search", app.searchHandler)
+		r.With(app.AuthMiddleware).Post("/uploads", app.uploadHandler)
+
+		// Files of the local object store, other drivers serve them directly
+		if local, ok := app.objects.(*objectstore.LocalStore); ok {
+			r.Handle("/media/*", http.StripPrefix("/v1/media", local))
+		}
+
+		r.Route("/comments/{commentID}", func(r chi.Router) {
+			r.Use(app.AuthMiddleware)
+			r.Use(app.getCommentMiddleware)
+			r.Patch("/", app.checkCommentOwnerShip("moderator", app.updateCommentHandler))
+			r.Delete("/", app.checkCommentOwnerShip("admin", app.deleteCommentHandler))
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activeUserHandler)
+			r.Post("/activate/resend", app.resendActivationHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthMiddleware)
+				r.Patch("/", app.updateSettingsHandler)
+				r.Get("/follow-requests", app.getFollowRequestsHandler)
+				r.Put("/follow-requests/{userID}", app.acceptFollowRequestHandler)
+				r.Delete("/follow-requests/{userID}", app.rejectFollowRequestHandler)
+				r.Get("/bookmarks", app.getBookmarksHandler)
+				r.Get("/posts", app.getMyPostsHandler)
+			})
 
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthMiddleware)
 				r.Get("/", app.getUserHandler)
+				r.Get("/followers", app.getFollowersHandler)
+				r.Get("/following", app.getFollowingHandler)
 				r.Put("/follows", app.followUserHandler)
 				r.Put("/unfollows", app.unfollowUserHandler)
+				r.Put("/block", app.blockUserHandler)
+				r.Delete("/block", app.unblockUserHandler)
+				r.Put("/mute", app.muteUserHandler)
+				r.Delete("/mute", app.unmuteUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
@@ -143,9 +270,29 @@ func (app *application) mount() http.Handler {
 			})
 		})
 
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthMiddleware)
+			r.Use(app.requireRole("admin"))
+			r.Get("/emails", app.getEmailsHandler)
+			r.Post("/emails/{emailID}/retry", app.retryEmailHandler)
+			r.Delete("/users/{userID}", app.deleteUserHandler)
+			r.Post("/users/{userID}/restore", app.restoreUserHandler)
+			r.Post("/posts/{postID}/restore", app.restorePostHandler)
+		})
+
+		r.Route("/sessions", func(r chi.Router) {
+			r.Use(app.AuthMiddleware)
+			r.Get("/", app.getSessionsHandler)
+			r.Delete("/", app.revokeAllSessionsHandler)
+			r.Delete("/{sessionID}", app.revokeSessionHandler)
+		})
+
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/users", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Put("/password/reset/{token}", app.resetPasswordHandler)
 		})
 
 	})
@@ -167,6 +314,12 @@ func (app *application) serve(h http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	// Background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app.startBackgroundJobs(ctx)
+
 	// GRACEFUL SHUTDOWN
 	shutdown := make(chan error)
 
@@ -197,6 +350,10 @@ func (app *application) serve(h http.Handler) error {
 		return err
 	}
 
+	// Stop background jobs and wait for running ones
+	cancel()
+	app.wg.Wait()
+
 	app.logger.Infow("Server shutdown successfully", "addr", app.config.addr, "env", app.config.env)
 
 	return nil
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -19,6 +21,8 @@ type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,gte=2,lte=255"`
 	Email    string `json:"email" validate:"required,email,lte=255"`
 	Password string `json:"password" validate:"required,gte=8,lte=72"`
+	// Locale of emails sent to the user, defaults to the Accept-Language header
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag,lte=10"`
 }
 
 type UserWithToken struct {
@@ -31,7 +35,8 @@ type UserWithToken struct {
 // @Tags			authentication
 // @Accept			json
 // @Produce		json
-// @Param			register	body		RegisterUserPayload	true	"Register payload"
+// @Param			register		body		RegisterUserPayload	true	"Register payload"
+// @Param			Accept-Language	header		string				false	"Preferred locale of emails"
 // @Success		200			{object}	UserWithToken
 // @Failure		400			{object}	error
 // @Failure		409			{object}	error
@@ -53,6 +58,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
+		Locale:   app.requestLocale(r, payload.Locale),
 		RoleID:   1,
 	}
 
@@ -69,7 +75,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	hash := sha256.Sum256([]byte(plainToken))
 	hashToken := hex.EncodeToString(hash[:])
 
-	if err := app.storage.Users.CreateAndInvite(r.Context(), user, hashToken, app.config.mail.exp); err != nil {
+	// The activation email is enqueued with the user, mail workers deliver it
+	if err := app.storage.Users.CreateAndInvite(r.Context(), user, hashToken, app.config.mail.exp, app.invitationEmail(plainToken)); err != nil {
 		switch {
 		case errors.Is(err, store.ErrConflict):
 			app.conflictResponse(w, r, err)
@@ -84,14 +91,16 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Token: plainToken,
 	}
 
-	// goroutines to send mail
-	go app.SendEmail(r.Context(), userWthToken)
-
 	if err := app.jsonResponse(w, http.StatusOK, userWthToken); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+var (
+	errInvalidCredentials  = errors.New("invalid email or password")
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+)
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,lte=255"`
 	Password string `json:"password" validate:"required,gte=8,lte=255"`
@@ -103,9 +112,11 @@ type CreateUserTokenPayload struct {
 // @Accept			json
 // @Produce		json
 // @Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-// @Success		200		{string}	string					"Token"
+// @Success		201		{object}	TokenPair
 // @Failure		400		{object}	error
 // @Failure		401		{object}	error
+// @Failure		423		{object}	error
+// @Failure		429		{object}	error
 // @Failure		500		{object}	error
 // @Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -121,65 +132,240 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	lockout := app.config.auth.lockout
+	ip := clientIP(r)
+
+	// Reject the request early if too many attempts come from the same IP
+	attempts, err := app.storage.LoginAttempts.CountFailedByIP(r.Context(), ip, lockout.window)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if attempts >= lockout.maxAttemptsPerIP {
+		app.rateLimitExceededResponse(w, r)
+		return
+	}
+
 	user, err := app.storage.Users.GetByEmail(r.Context(), payload.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
+			store.CompareDummyPassword(payload.Password)
+			app.loginFailed(w, r, 0, ip)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    "GOssage",
-		Subject:   strconv.FormatInt(user.ID, 10),
-		Audience:  []string{"GOssage"},
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(app.config.auth.token.exp)),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	if user.IsLocked() {
+		app.accountLockedResponse(w, r, store.ErrAccountLocked, time.Until(*user.LockedUntil))
+		return
 	}
 
-	jwtToken, err := app.authenticator.GenerateToken(claims)
+	match, err := user.Password.Matches(payload.Password)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	response := struct {
-		AccessToken string `json:"access_token"`
-	}{
-		AccessToken: jwtToken,
+	if !match {
+		app.loginFailed(w, r, user.ID, ip)
+		return
+	}
+
+	if err = app.storage.LoginAttempts.Reset(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.issueTokens(r.Context(), user.ID, r.UserAgent(), ip)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusCreated, response); err != nil {
+	if err = app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-// SendEmail sends an invitation to user Email, with 3 time retries,
-// If error occurred, deletes the current user and its invitation.
-func (app *application) SendEmail(ctx context.Context, user UserWithToken) {
-	activationUrl := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, user.Token)
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,lte=255"`
+}
+
+// @Summary		Refreshes a token
+// @Description	Exchanges a refresh token for a new access token and refresh token,
+// @Description	reusing a refresh token revokes every token of its family
+// @Tags			authentication
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+// @Success		200		{object}	TokenPair
+// @Failure		400		{object}	error
+// @Failure		401		{object}	error
+// @Failure		500		{object}	error
+// @Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	data := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationUrl,
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	err := app.mailer.Send(internal.TemplatePath, []string{user.Email}, data)
+	nextToken, err := generateRefreshToken()
 	if err != nil {
-		app.logger.Infow("error sending email", "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
 
-		if err = app.storage.Users.Delete(ctx, user.ID); err != nil {
-			app.logger.Infow("error deleting user", "error", err)
+	refreshToken, err := app.storage.RefreshTokens.Rotate(r.Context(), payload.RefreshToken, nextToken, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, errInvalidRefreshToken)
+		case errors.Is(err, store.ErrTokenReused):
+			// The session is already revoked in database, keep revocation set in sync
+			if cacheErr := app.revokeSessionsCache(r.Context(), refreshToken.FamilyID); cacheErr != nil {
+				app.internalServerError(w, r, cacheErr)
+				return
+			}
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
 		}
+		return
+	}
 
+	// Make sure the user is still active
+	if _, err = app.storage.Users.GetByID(r.Context(), refreshToken.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, errInvalidRefreshToken)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(refreshToken.UserID, refreshToken.FamilyID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: nextToken,
+	}
+
+	if err = app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens starts a new session for the user,
+// returns a short-lived access token and an opaque refresh token.
+func (app *application) issueTokens(ctx context.Context, userID int64, userAgent, ip string) (*TokenPair, error) {
+	plainToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IPAddress: ip,
+		Expiry:    time.Now().Add(app.config.auth.token.refreshExp),
 	}
 
-	app.logger.Infow("email sent successfully!", "email", user.Email)
+	if err = app.storage.Sessions.Create(ctx, session, plainToken); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(userID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+	}, nil
+}
+
+// generateAccessToken signs a JWT for the user, token ID refers to the session
+// so the access token is rejected once its session has been revoked.
+func (app *application) generateAccessToken(userID int64, sessionID string) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    app.config.auth.token.iss,
+		Subject:   strconv.FormatInt(userID, 10),
+		Audience:  []string{app.config.auth.token.iss},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(app.config.auth.token.exp)),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ID:        sessionID,
+	}
+
+	return app.authenticator.GenerateToken(claims)
+}
+
+// generateRefreshToken generates a random opaque refresh token.
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// loginFailed records a failed login attempt, responses http.StatusLocked
+// if the attempt locked the account, otherwise http.StatusUnauthorized.
+func (app *application) loginFailed(w http.ResponseWriter, r *http.Request, userID int64, ip string) {
+	lockout := app.config.auth.lockout
+
+	err := app.storage.LoginAttempts.RecordFailure(r.Context(), userID, ip, lockout.maxAttempts, lockout.window, lockout.duration)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrAccountLocked):
+			app.accountLockedResponse(w, r, err, lockout.duration)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.unauthorizedErrorResponse(w, r, errInvalidCredentials)
+}
+
+// invitationEmail builds the email of the activation link with the plain token to user Email.
+// Users who never activate their account are purged by the janitor.
+func (app *application) invitationEmail(token string) store.EmailFunc {
+	return func(user *store.User) (*store.Email, error) {
+		activationUrl := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, token)
+
+		data := struct {
+			Username      string
+			ActivationURL string
+		}{
+			Username:      user.Username,
+			ActivationURL: activationUrl,
+		}
+
+		return newEmail(internal.TemplatePath, user.Locale, []string{user.Email}, data)
+	}
 }
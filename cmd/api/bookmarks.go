@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+// bookmarkPostHandler saves a post for later.
+//
+//	@Summary		Bookmark post
+//	@Description	save a post in the bookmarks of the authenticated user
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	if err := app.storage.Bookmarks.Add(r.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// unbookmarkPostHandler removes a post from the bookmarks of the user.
+//
+//	@Summary		Remove bookmark
+//	@Description	remove a post from the bookmarks of the authenticated user
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	if err := app.storage.Bookmarks.Remove(r.Context(), user.ID, post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBookmarksHandler lists posts bookmarked by the authenticated user, most recently bookmarked first.
+// Pass next_cursor or prev_cursor of the response as cursor to get another page.
+//
+//	@Summary		List bookmarks
+//	@Description	list bookmarked posts with the same metadata as the feed
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.Bookmark
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/me/bookmarks [get]
+func (app *application) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	q := store.PaginatedBookmarkQuery{
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bookmarks, page, err := app.storage.Bookmarks.GetByUserID(r.Context(), user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if bookmarks == nil {
+		bookmarks = []store.Bookmark{}
+	}
+
+	for i := range bookmarks {
+		app.setAttachmentURLs(bookmarks[i].Attachments)
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, bookmarks, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,lte=1000"`
+	// ParentID is the comment being replied to
+	ParentID *int64 `json:"parent_id" validate:"omitempty,min=1"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,lte=1000"`
+}
+
+const (
+	commentCtx contextType = "comment"
+	commentID  string      = "commentID"
+)
+
+var (
+	errEditConflict     = errors.New("the resource has been modified by another request, please try again")
+	errParentNotFound   = errors.New("parent comment does not belong to the post")
+	errMaxDepthExceeded = errors.New("maximum reply depth exceeded")
+)
+
+// getCommentsHandler lists top level comments of a post, or replies of parent_id,
+// oldest first. Pass next_cursor or prev_cursor of the response as cursor to get another page.
+//
+//	@Summary		List comments
+//	@Description	list comments of a post page by page, with reply counts to lazy-load threads
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path	int		true	"Post ID"
+//	@Param			parent_id	query	int		false	"list replies of this comment"
+//	@Param			cursor		query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit		query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.Comment
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	q := store.PaginatedCommentQuery{
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comments, page, err := app.storage.Comments.GetByPostID(r.Context(), post.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if comments == nil {
+		comments = []store.Comment{}
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, comments, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createCommentHandler comments on the post by given post ID.
+//
+//	@Summary		Create a comment
+//	@Description	comment on a post, or reply to parent_id comment of the post
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int						true	"Post ID"
+//	@Param			comment	body	CreateCommentPayload	true	"Create comment payload"
+//	@Security		ApiKeyAuth
+//	@Success		201	{object}	store.Comment
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayload
+
+	user := getUserFromContext(r)
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment := store.Comment{
+		UserID:   user.ID,
+		PostID:   post.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+	}
+
+	// Users blocking each other cannot comment on posts of the other
+	blocked, err := app.storage.Restrictions.IsBlocked(r.Context(), user.ID, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if payload.ParentID != nil {
+		parent, err := app.storage.Comments.GetByID(r.Context(), *payload.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, errParentNotFound)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if parent.PostID != post.ID {
+			app.badRequestResponse(w, r, errParentNotFound)
+			return
+		}
+
+		comment.Depth = parent.Depth + 1
+		if comment.Depth > app.config.comments.maxDepth {
+			app.badRequestResponse(w, r, errMaxDepthExceeded)
+			return
+		}
+
+		// Nor reply to comments of the other, under posts of anyone
+		blocked, err = app.storage.Restrictions.IsBlocked(r.Context(), user.ID, parent.UserID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if blocked {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	comment.User.ID = user.ID
+	comment.User.Username = user.Username
+
+	if err := app.storage.Comments.Create(r.Context(), &comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateCommentHandler edits content of a comment. Clients send the version they read
+// as If-Match ETag, concurrent edits of the same comment are rejected.
+//
+//	@Summary		Update comment
+//	@Description	update comment by id, without If-Match responses 428, if the comment changed since
+//	@Description	the If-Match ETag (its version, e.g. "3") responses 412, if it changes during the update responses 409
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path	int						true	"Comment ID"
+//	@Param			If-Match	header	string					true	"ETag of the edited version of the comment"
+//	@Param			comment		body	UpdateCommentPayload	true	"Update comment payload"
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	store.Comment
+//	@Header			200	{string}	ETag	"new version of the comment"
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		412	{object}	error
+//	@Failure		428	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCommentPayload
+	comment := getCommentFromContext(r)
+
+	if !app.checkIfMatch(w, r, comment.Version) {
+		return
+	}
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment.Content = payload.Content
+
+	// The version is checked again by the update, in case the comment changed since it was read
+	if err := app.storage.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictResponse(w, r, errEditConflict)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(comment.Version))
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteCommentHandler deletes a comment by given comment ID.
+//
+//	@Summary		Delete comment
+//	@Description	delete comment by id
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			commentID	path	int	true	"Comment ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromContext(r)
+
+	if err := app.storage.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCommentMiddleware gets comment by given commentID and used as a middleware.
+func (app *application) getCommentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := parseID(r, commentID)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		comment, err := app.storage.Comments.GetByID(r.Context(), commentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromContext(r *http.Request) *store.Comment {
+	return r.Context().Value(commentCtx).(*store.Comment)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/mailer"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// enqueueEmail stores the email in the outbox, it will be delivered by mail workers
+// rendered in the given locale.
+func (app *application) enqueueEmail(ctx context.Context, templateFile, locale string, toEmail []string, data any) error {
+	email, err := newEmail(templateFile, locale, toEmail, data)
+	if err != nil {
+		return err
+	}
+
+	return app.storage.Emails.Enqueue(ctx, email)
+}
+
+// newEmail builds an outbox email of the template rendered with data in the given locale.
+func newEmail(templateFile, locale string, toEmail []string, data any) (*store.Email, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.Email{
+		Template:   templateFile,
+		Locale:     locale,
+		Recipients: toEmail,
+		Data:       b,
+	}, nil
+}
+
+// deliverEmails claims due emails from the outbox and sends them,
+// failed emails are retried with exponential backoff until they are dead.
+// Emails are claimed one at a time, so the lease of an email only has to
+// outlast its own delivery, not the delivery of a whole batch.
+func (app *application) deliverEmails(ctx context.Context) error {
+	outbox := app.config.mail.outbox
+
+	for i := 0; i < outbox.batchSize; i++ {
+		emails, err := app.storage.Emails.ClaimPending(ctx, 1, outbox.lease)
+		if err != nil {
+			return err
+		}
+
+		if len(emails) == 0 {
+			return nil
+		}
+
+		if err = app.deliverEmail(ctx, &emails[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverEmail sends a claimed email, then marks it as sent or failed.
+func (app *application) deliverEmail(ctx context.Context, email *store.Email) error {
+	outbox := app.config.mail.outbox
+
+	var data map[string]any
+	err := json.Unmarshal(email.Data, &data)
+	if err == nil {
+		err = app.mailer.Send(email.Template, email.Locale, email.Recipients, data)
+	}
+
+	if err == nil {
+		if err = app.storage.Emails.MarkSent(ctx, email.ID); err != nil {
+			return err
+		}
+		app.logger.Infow("email sent successfully!", "id", email.ID, "template", email.Template)
+		return nil
+	}
+
+	dead := email.Attempts >= outbox.maxAttempts
+	nextAttemptAt := time.Now().Add(backoff(email.Attempts, outbox.backoffBase, outbox.backoffMax))
+
+	app.logger.Infow("error sending email", "id", email.ID, "attempts", email.Attempts, "dead", dead, "error", err)
+
+	return app.storage.Emails.MarkFailed(ctx, email.ID, err.Error(), nextAttemptAt, dead)
+}
+
+// requestLocale returns the supported email locale preferred by the client,
+// from the locale parameter then the Accept-Language header.
+func (app *application) requestLocale(r *http.Request, locale string) string {
+	preferred := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if locale != "" {
+		preferred = append([]string{locale}, preferred...)
+	}
+
+	return app.templates.Match(preferred...)
+}
+
+// parseAcceptLanguage returns language tags of the Accept-Language header
+// ordered by quality, e.g. "vi-VN,vi;q=0.9,en;q=0.8" gives [vi-VN vi en].
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name    string
+		quality float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			v, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = v
+		}
+
+		if quality > 0 {
+			tags = append(tags, tag{name: name, quality: quality})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.name
+	}
+
+	return names
+}
+
+// backoff returns base * 2^(attempts-1), capped at max.
+func backoff(attempts int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+
+	return d
+}
+
+// getEmailsHandler lists emails of the outbox by status.
+//
+//	@Summary		List outbox emails
+//	@Description	list outbox emails by status, dead emails by default
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			status	query	string	false	"pending, sent or dead"
+//	@Param			limit	query	int		false	"limit"
+//	@Param			offset	query	int		false	"offset"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.Email
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/admin/emails [get]
+func (app *application) getEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	qr := r.URL.Query()
+
+	params := struct {
+		Status string `validate:"oneof=pending sent dead"`
+		Limit  int    `validate:"min=1,max=100"`
+		Offset int    `validate:"min=0"`
+	}{
+		Status: store.EmailStatusDead,
+		Limit:  20,
+	}
+
+	var err error
+	if status := qr.Get("status"); status != "" {
+		params.Status = status
+	}
+
+	if limit := qr.Get("limit"); limit != "" {
+		if params.Limit, err = strconv.Atoi(limit); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if offset := qr.Get("offset"); offset != "" {
+		if params.Offset, err = strconv.Atoi(offset); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if err = Validate.Struct(params); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	emails, err := app.storage.Emails.GetByStatus(r.Context(), params.Status, params.Limit, params.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusOK, emails); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// retryEmailHandler moves a dead email back to the outbox queue.
+//
+//	@Summary		Retry outbox email
+//	@Description	move a dead email back to the queue
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			emailID	path	int	true	"Email ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/admin/emails/{emailID}/retry [post]
+func (app *application) retryEmailHandler(w http.ResponseWriter, r *http.Request) {
+	emailID, err := parseID(r, "emailID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err = app.storage.Emails.Retry(r.Context(), emailID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// debugMailsHandler exposes emails sent by the in-memory mail driver,
+// it is only mounted when the driver is enabled.
+//
+//	@Summary		Sent emails
+//	@Description	emails sent by the in-memory mail driver, available when MAIL_DRIVER=memory
+//	@Tags			Ops
+//	@Produce		json
+//	@Success		200	{array}		mailer.Message
+//	@Failure		404	{object}	error
+//	@Router			/debug/mails [get]
+func (app *application) debugMailsHandler(w http.ResponseWriter, r *http.Request) {
+	m, ok := app.mailer.(*mailer.MemoryMailer)
+	if !ok {
+		app.notFoundResponse(w, r, errors.New("in-memory mail driver is not enabled"))
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, m.Messages()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
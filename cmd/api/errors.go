@@ -2,6 +2,8 @@ package main
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -36,6 +38,61 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Infow(
+		"precondition failed",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	writeJSONError(w, http.StatusPreconditionFailed, err.Error())
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Infow(
+		"payload too large",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Infow(
+		"unsupported media type",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+}
+
+func (app *application) unprocessableEntityResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Infow(
+		"unprocessable entity",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Infow(
+		"precondition required",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	writeJSONError(w, http.StatusPreconditionRequired, err.Error())
+}
+
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logger.Infow(
 		"not found",
@@ -78,3 +135,15 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusTooManyRequests, "too many requests")
 }
+
+func (app *application) accountLockedResponse(w http.ResponseWriter, r *http.Request, err error, retryAfter time.Duration) {
+	app.logger.Infow(
+		"account locked",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err.Error(),
+	)
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	writeJSONError(w, http.StatusLocked, err.Error())
+}
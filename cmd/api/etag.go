@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errPreconditionRequired = errors.New("the If-Match header is required, send the ETag of the edited version")
+
+// versionETag returns the strong ETag of a resource at the given version.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// matchETag reports whether the If-Match or If-None-Match header value matches etag,
+// the header is either "*" or a comma separated list of ETags.
+// If-Match uses the strong comparison, weak ETags never match,
+// If-None-Match uses the weak comparison, weak ETags are compared by their opaque value.
+func matchETag(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch makes sure the client edits the given version of a resource, so edits made
+// meanwhile by someone else are not overwritten. Responds 428 without If-Match header,
+// or 412 if it does not match, and returns false.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		app.preconditionRequiredResponse(w, r, errPreconditionRequired)
+		return false
+	}
+
+	if !matchETag(ifMatch, versionETag(version), false) {
+		app.preconditionFailedResponse(w, r, errEditConflict)
+		return false
+	}
+
+	return true
+}
@@ -13,13 +13,17 @@ import (
 //
 //	@Security		ApiKeyAuth
 //
-// @Param			limit	query		int		false	"limit"
-// @Param			since	query		string	false	"since"
-// @Param			until	query		string	false	"until"
-// @Param			offset	query		int		false	"offset"
-// @Param			sort	query		string	false	"sort"
-// @Param			search	query		string	false	"search"
-// @Success		200		{object}	store.Post
+// @Param			limit		query		int		false	"limit"
+// @Param			since		query		string	false	"posts published at or after, RFC 3339 or 2006-01-02 15:04:05 (UTC)"
+// @Param			until		query		string	false	"posts published before, RFC 3339 or 2006-01-02 15:04:05 (UTC)"
+// @Param			tags		query		string	false	"comma separated tags"
+// @Param			tag_match	query		string	false	"any (default) or all of the tags"
+// @Param			author		query		string	false	"username of the author"
+// @Param			cursor		query		string	false	"next_cursor or prev_cursor of another page"
+// @Param			sort		query		string	false	"sort"
+// @Param			search		query		string	false	"search"
+// @Success		200		{array}		store.PostWithMetadata
+// @Header			200		{string}	Link	"URLs of the next and previous pages"
 // @Failure		400		{object}	error
 // @Failure		409		{object}	error
 // @Failure		404		{object}	error
@@ -30,9 +34,9 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	userID := user.ID
 
 	p := store.PaginatedFeedQuery{
-		Limit:  20,
-		Offset: 0,
-		Sort:   "desc",
+		Limit:    20,
+		Sort:     "desc",
+		TagMatch: "any",
 	}
 
 	err := p.Parse(r)
@@ -47,13 +51,21 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	feed, err := app.storage.Posts.GetUserFeed(r.Context(), userID, p)
+	feed, page, err := app.storage.Posts.GetUserFeed(r.Context(), userID, p)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if feed == nil {
+		feed = []store.PostWithMetadata{}
+	}
+
+	for i := range feed {
+		app.setAttachmentURLs(feed[i].Attachments)
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, feed, page); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
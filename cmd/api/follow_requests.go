@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+// getFollowRequestsHandler lists pending requests to follow the authenticated user.
+//
+//	@Summary		List follow requests
+//	@Description	list pending requests to follow the authenticated private account
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.FollowRequest
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	q := store.PaginatedFollowQuery{
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	requests, page, err := app.storage.Followers.GetFollowRequests(r.Context(), user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if requests == nil {
+		requests = []store.FollowRequest{}
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, requests, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Accept follow request
+// @Description	the requester starts following the authenticated user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"Requester ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/me/follow-requests/{userID} [put]
+func (app *application) acceptFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.storage.Followers.AcceptFollowRequest)
+}
+
+// @Summary		Reject follow request
+// @Description	delete the pending follow request of the requester
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"Requester ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/me/follow-requests/{userID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.answerFollowRequest(w, r, app.storage.Followers.RejectFollowRequest)
+}
+
+type followRequestAnswerFunc func(ctx context.Context, userID, requesterID int64) error
+
+func (app *application) answerFollowRequest(w http.ResponseWriter, r *http.Request, answer followRequestAnswerFunc) {
+	user := getUserFromContext(r)
+
+	requesterID, err := parseID(r, "userID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err = answer(r.Context(), user.ID, requesterID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// startBackgroundJobs starts jobs running inside the API process,
+// they stop when ctx is cancelled.
+func (app *application) startBackgroundJobs(ctx context.Context) {
+	app.runPeriodically(ctx, "janitor", app.config.janitor.interval, app.purgeUnactivatedAccounts)
+	app.runPeriodically(ctx, "login-attempts-janitor", app.config.janitor.interval, app.purgeLoginAttempts)
+	app.runPeriodically(ctx, "trash", app.config.trash.interval, app.purgeTrash)
+	app.runPeriodically(ctx, "scheduler", app.config.scheduler.interval, app.publishScheduledPosts)
+	app.runPeriodically(ctx, "uploads-gc", app.config.uploads.gcInterval, app.collectOrphanUploads)
+
+	// Mail workers claim emails with SKIP LOCKED, so they never pick the same email
+	for i := 0; i < app.config.mail.outbox.workers; i++ {
+		app.runPeriodically(ctx, fmt.Sprintf("mail-worker-%d", i), app.config.mail.outbox.pollInterval, app.deliverEmails)
+	}
+}
+
+// runPeriodically runs fn every interval in its own goroutine until ctx is cancelled.
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					app.logger.Infow("background job failed", "job", name, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// purgeUnactivatedAccounts deletes expired invitations, then users
+// who never activated their account after the grace period.
+func (app *application) purgeUnactivatedAccounts(ctx context.Context) error {
+	invitations, err := app.storage.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := app.storage.Users.DeleteUnactivated(ctx, app.config.janitor.gracePeriod)
+	if err != nil {
+		return err
+	}
+
+	if invitations > 0 || users > 0 {
+		app.logger.Infow("purged unactivated accounts", "invitations", invitations, "users", users)
+	}
+
+	return nil
+}
+
+// purgeLoginAttempts deletes failed login attempts older than the lockout window.
+func (app *application) purgeLoginAttempts(ctx context.Context) error {
+	attempts, err := app.storage.LoginAttempts.DeleteExpired(ctx, app.config.auth.lockout.window)
+	if err != nil {
+		return err
+	}
+
+	if attempts > 0 {
+		app.logger.Infow("purged login attempts", "attempts", attempts)
+	}
+
+	return nil
+}
+
+// purgeTrash permanently deletes posts and users deleted longer than the retention period ago.
+func (app *application) purgeTrash(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-app.config.trash.retention)
+
+	posts, err := app.storage.Posts.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	users, err := app.storage.Users.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+
+	if posts > 0 || users > 0 {
+		app.logger.Infow("purged trash", "posts", posts, "users", users)
+	}
+
+	return nil
+}
+
+// publishScheduledPosts publishes scheduled posts when they come due.
+func (app *application) publishScheduledPosts(ctx context.Context) error {
+	published, err := app.storage.Posts.PublishDue(ctx)
+	if err != nil {
+		return err
+	}
+
+	if published > 0 {
+		app.logger.Infow("published scheduled posts", "posts", published)
+	}
+
+	return nil
+}
+
+// orphanBatchSize is the largest number of orphan uploads deleted per run of the collector
+const orphanBatchSize = 100
+
+// collectOrphanUploads deletes uploads which were never attached to a post, or whose post was purged,
+// after the orphan TTL. Rows are deleted first so the files cannot be attached meanwhile,
+// files failing to delete are only logged.
+func (app *application) collectOrphanUploads(ctx context.Context) error {
+	createdBefore := time.Now().Add(-app.config.uploads.orphanTTL)
+
+	keys, err := app.storage.Attachments.DeleteOrphans(ctx, createdBefore, orphanBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err = app.objects.Delete(ctx, key); err != nil {
+			app.logger.Infow("error deleting orphan upload", "key", key, "error", err)
+		}
+	}
+
+	if len(keys) > 0 {
+		app.logger.Infow("collected orphan uploads", "uploads", len(keys))
+	}
+
+	return nil
+}
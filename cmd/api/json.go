@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"github.com/minhnghia2k3/GOssage/internal/store"
 	"io"
 	"net/http"
 	"strings"
@@ -91,3 +92,38 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data any
 
 	return writeJSON(w, status, &envelop{Data: data})
 }
+
+// paginatedJSONResponse writes a page of data with cursors of the pages around it,
+// which are also advertised in the Link header.
+func (app *application) paginatedJSONResponse(w http.ResponseWriter, r *http.Request, status int, data any, page store.Page) error {
+	type envelop struct {
+		Data       any    `json:"data"`
+		NextCursor string `json:"next_cursor,omitempty"`
+		PrevCursor string `json:"prev_cursor,omitempty"`
+	}
+
+	var links []string
+	if page.Next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, page.Next)))
+	}
+	if page.Prev != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, page.Prev)))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return writeJSON(w, status, &envelop{Data: data, NextCursor: page.Next, PrevCursor: page.Prev})
+}
+
+// pageURL returns the request URL with its cursor parameter replaced by cursor.
+func pageURL(r *http.Request, cursor string) string {
+	u := *r.URL
+
+	qr := u.Query()
+	qr.Set("cursor", cursor)
+	u.RawQuery = qr.Encode()
+
+	return u.String()
+}
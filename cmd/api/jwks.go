@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/minhnghia2k3/GOssage/internal/auth"
+	"net/http"
+)
+
+// jwksHandler publishes public keys used to sign access tokens,
+// so other services can verify tokens without sharing a secret.
+//
+//	@Summary		JSON Web Key Set
+//	@Description	public keys used to verify access tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Failure		500	{object}	error
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	// Symmetric authenticator has no public key to publish
+	set := auth.JWKS{Keys: []auth.JWK{}}
+
+	if keySet, ok := app.authenticator.(auth.KeySet); ok {
+		var err error
+		set, err = keySet.JWKS()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
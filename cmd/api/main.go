@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"expvar"
+	"fmt"
 	"github.com/joho/godotenv"
+	"github.com/minhnghia2k3/GOssage/internal"
 	"github.com/minhnghia2k3/GOssage/internal/auth"
 	"github.com/minhnghia2k3/GOssage/internal/database"
 	"github.com/minhnghia2k3/GOssage/internal/env"
 	"github.com/minhnghia2k3/GOssage/internal/mailer"
+	"github.com/minhnghia2k3/GOssage/internal/objectstore"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"github.com/minhnghia2k3/GOssage/internal/store/cache"
 	"github.com/redis/go-redis/v9"
@@ -50,21 +54,43 @@ func main() {
 		},
 		env: env.GetString("ENV", "development"),
 		mail: mailConfig{
-			exp:       24 * time.Hour, // 1 day
-			fromEmail: env.GetString("FROM_EMAIL", ""),
+			driver:         env.GetString("MAIL_DRIVER", "smtp"),
+			fileDir:        env.GetString("MAIL_FILE_DIR", "./tmp/mails"),
+			exp:            24 * time.Hour, // 1 day
+			resetExp:       time.Hour,
+			resendCooldown: 2 * time.Minute,
+			fromEmail:      env.GetString("FROM_EMAIL", ""),
 			dialer: dialer{
-				host:     env.GetString("MAILTRAP_HOST", "sandbox.smtp.mailtrap.io"),
-				port:     env.GetInt("MAILTRAP_PORT", 2525),
-				username: env.GetString("MAILTRAP_USERNAME", ""),
-				password: env.GetString("MAILTRAP_PASSWORD", ""),
+				host:        env.GetString("SMTP_HOST", env.GetString("MAILTRAP_HOST", "sandbox.smtp.mailtrap.io")),
+				port:        env.GetInt("SMTP_PORT", env.GetInt("MAILTRAP_PORT", 2525)),
+				username:    env.GetString("SMTP_USERNAME", env.GetString("MAILTRAP_USERNAME", "")),
+				password:    env.GetString("SMTP_PASSWORD", env.GetString("MAILTRAP_PASSWORD", "")),
+				implicitTLS: env.GetString("SMTP_TLS", "starttls") == "tls",
+			},
+			outbox: outboxConfig{
+				workers:      env.GetInt("MAIL_WORKERS", 4),
+				pollInterval: 5 * time.Second,
+				batchSize:    10,
+				lease:        time.Minute,
+				maxAttempts:  8,
+				backoffBase:  30 * time.Second,
+				backoffMax:   time.Hour,
 			},
 		},
 		frontendURL: env.GetString("FRONTEND_URL", "http://localhost:3000"),
 		auth: authConfig{
 			token: tokenConfig{
-				secret: env.GetString("JWT_SECRET_KEY", "example"),
-				exp:    3 * 24 * time.Hour, // 3 days
-				iss:    "GOssage",
+				secret:     env.GetString("JWT_SECRET_KEY", "example"),
+				keyFiles:   env.GetStrings("JWT_PRIVATE_KEYS", nil),
+				exp:        15 * time.Minute,
+				refreshExp: 30 * 24 * time.Hour, // 30 days
+				iss:        "GOssage",
+			},
+			lockout: lockoutConfig{
+				maxAttempts:      env.GetInt("LOGIN_MAX_ATTEMPTS", 5),
+				maxAttemptsPerIP: env.GetInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+				window:           15 * time.Minute,
+				duration:         15 * time.Minute,
 			},
 		},
 		redisConfig: redisConfig{
@@ -78,12 +104,49 @@ func main() {
 			burst:   int64(env.GetInt("RATE_LIMITER_BURST", 4)),
 			enabled: env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		janitor: janitorConfig{
+			interval:    time.Hour,
+			gracePeriod: 7 * 24 * time.Hour, // 7 days
+		},
+		scheduler: schedulerConfig{
+			interval: 30 * time.Second,
+		},
+		trash: trashConfig{
+			interval:  time.Hour,
+			retention: time.Duration(env.GetInt("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		},
+		comments: commentConfig{
+			maxDepth: env.GetInt("COMMENT_MAX_DEPTH", 5),
+		},
+		uploads: uploadsConfig{
+			driver:    env.GetString("UPLOAD_DRIVER", "local"),
+			dir:       env.GetString("UPLOAD_DIR", "./uploads"),
+			publicURL: env.GetString("UPLOAD_PUBLIC_URL", "http://localhost:8080/v1/media"),
+			s3: objectstore.S3Config{
+				Endpoint:  env.GetString("S3_ENDPOINT", "localhost:9000"),
+				Region:    env.GetString("S3_REGION", "us-east-1"),
+				Bucket:    env.GetString("S3_BUCKET", "gossage"),
+				AccessKey: env.GetString("S3_ACCESS_KEY", "minioadmin"),
+				SecretKey: env.GetString("S3_SECRET_KEY", "minioadmin"),
+				UseSSL:    env.GetBool("S3_USE_SSL", false),
+				PublicURL: env.GetString("S3_PUBLIC_URL", ""),
+			},
+			maxBytes:   int64(env.GetInt("UPLOAD_MAX_BYTES", 10<<20)), // 10 MiB
+			orphanTTL:  24 * time.Hour,
+			gcInterval: time.Hour,
+		},
 	}
 
 	// Initialize structured logger
 	logger := initLogger()
 	defer logger.Sync()
 
+	trustedProxies, err := parseTrustedProxies(env.GetStrings("TRUSTED_PROXIES", nil))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	cfg.trustedProxies = trustedProxies
+
 	// Initialize connection pool
 	db, err := database.New(
 		cfg.dbConfig.dsn,
@@ -100,22 +163,50 @@ func main() {
 	// Initialize storage layer
 	s := store.NewStorage(db)
 
+	// Parse email templates once, so a broken template fails at startup
+	templates, err := mailer.LoadTemplates(internal.FS, "templates")
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	// Initialize mailer
-	m := mailer.NewMailer(
-		cfg.mail.fromEmail,
-		cfg.mail.dialer.host,
-		cfg.mail.dialer.username,
-		cfg.mail.dialer.password,
-		cfg.mail.dialer.port,
-	)
+	m, err := newMailer(cfg.mail, templates)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("mailer initialized", "driver", cfg.mail.driver, "locales", templates.Locales())
 
-	// Initialize JWTAuthenticator
-	jwtAuthenticator := auth.NewJWTAuthenticator(
+	// Initialize object store of uploaded files
+	objects, err := newObjectStore(cfg.uploads)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("object store initialized", "driver", cfg.uploads.driver)
+
+	// Initialize Authenticator, asymmetric keys take precedence over the shared secret
+	var authenticator auth.Authenticator = auth.NewJWTAuthenticator(
 		cfg.auth.token.secret,
 		cfg.auth.token.iss,
 		cfg.auth.token.iss,
 	)
 
+	if len(cfg.auth.token.keyFiles) > 0 {
+		keys := make([]*auth.SigningKey, 0, len(cfg.auth.token.keyFiles))
+		for _, path := range cfg.auth.token.keyFiles {
+			key, err := auth.LoadSigningKey(path)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			keys = append(keys, key)
+		}
+
+		authenticator, err = auth.NewKeySetAuthenticator(keys, cfg.auth.token.iss, cfg.auth.token.iss)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Infow("asymmetric signing keys loaded", "active_kid", keys[0].ID, "keys", len(keys))
+	}
+
 	// Initialize Redis Storage
 	var rdb *redis.Client
 	if cfg.redisConfig.enabled {
@@ -131,10 +222,16 @@ func main() {
 		storage:       s,
 		logger:        logger,
 		mailer:        m,
-		authenticator: jwtAuthenticator,
+		templates:     templates,
+		objects:       objects,
+		authenticator: authenticator,
 		cacheStorage:  redisStorage,
 	}
 
+	if err = app.backfillRevocationSet(context.Background()); err != nil {
+		logger.Fatal(err)
+	}
+
 	// Metric collected
 	expvar.NewString("version").Set(version)
 	expvar.Publish("database", expvar.Func(func() any {
@@ -151,6 +248,40 @@ func main() {
 	}
 }
 
+// newMailer creates the mailer of the configured MAIL_DRIVER.
+func newMailer(cfg mailConfig, templates *mailer.Templates) (internal.Client, error) {
+	switch cfg.driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			templates,
+			cfg.fromEmail,
+			cfg.dialer.host,
+			cfg.dialer.username,
+			cfg.dialer.password,
+			cfg.dialer.port,
+			cfg.dialer.implicitTLS,
+		), nil
+	case "file":
+		return mailer.NewFileMailer(templates, cfg.fromEmail, cfg.fileDir)
+	case "memory":
+		return mailer.NewMemoryMailer(templates, cfg.fromEmail), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.driver)
+	}
+}
+
+// newObjectStore creates the object store of the configured UPLOAD_DRIVER.
+func newObjectStore(cfg uploadsConfig) (objectstore.Store, error) {
+	switch cfg.driver {
+	case "local":
+		return objectstore.NewLocalStore(cfg.dir, cfg.publicURL)
+	case "s3":
+		return objectstore.NewS3Store(context.Background(), cfg.s3)
+	default:
+		return nil, fmt.Errorf("unknown upload driver %q", cfg.driver)
+	}
+}
+
 func initLogger() *zap.SugaredLogger {
 	rawJSON := []byte(`{
 	  "level": "info",
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"errors"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"golang.org/x/time/rate"
 	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"sync"
@@ -47,6 +49,25 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Reject tokens whose session has been revoked
+		claims, _ := token.Claims.(jwt.MapClaims)
+		sessionID, _ := claims["jti"].(string)
+		if sessionID == "" {
+			app.unauthorizedErrorResponse(w, r, errors.New("missing token id"))
+			return
+		}
+
+		revoked, err := app.isSessionRevoked(r.Context(), sessionID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unauthorizedErrorResponse(w, r, errors.New("session has been revoked"))
+			return
+		}
+
 		userID, err := strconv.ParseInt(subject, 10, 64)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -60,6 +81,7 @@ func (app *application) AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, sessionID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -93,11 +115,23 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 }
 
 func (app *application) checkPostOwnerShip(role string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnerShip(role, func(r *http.Request) int64 {
+		return r.Context().Value(postCtx).(*store.Post).UserID
+	}, next)
+}
+
+func (app *application) checkCommentOwnerShip(role string, next http.HandlerFunc) http.HandlerFunc {
+	return app.checkOwnerShip(role, func(r *http.Request) int64 {
+		return getCommentFromContext(r).UserID
+	}, next)
+}
+
+// checkOwnerShip allows the owner of the resource, or users whose role level is at least the level of role.
+func (app *application) checkOwnerShip(role string, ownerID func(r *http.Request) int64, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(userCtx).(*store.User)
-		post := r.Context().Value(postCtx).(*store.Post)
 
-		if post.UserID == user.ID {
+		if ownerID(r) == user.ID {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -117,6 +151,28 @@ func (app *application) checkPostOwnerShip(role string, next http.HandlerFunc) h
 	}
 }
 
+// requireRole allows only users whose role level is at least the level of roleName.
+func (app *application) requireRole(roleName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r)
+
+			allow, err := app.checkPriority(r.Context(), user, roleName)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allow {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkPriority(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.storage.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -128,6 +184,90 @@ func (app *application) checkPriority(ctx context.Context, user *store.User, rol
 	return user.Role.Level >= role.Level, nil
 }
 
+// clientIP returns the IP address of the client without port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// realIP middleware may already set RemoteAddr without port
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+// realIP sets RemoteAddr to the client IP address forwarded by a trusted proxy.
+// Forwarding headers of other requests are ignored, as any client can set them.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := app.forwardedIP(r); ok {
+			r.RemoteAddr = ip
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP returns the rightmost address of X-Forwarded-For which is not a trusted proxy,
+// or X-Real-IP, if the request comes from a trusted proxy.
+func (app *application) forwardedIP(r *http.Request) (string, bool) {
+	remote, err := netip.ParseAddr(clientIP(r))
+	if err != nil || !app.isTrustedProxy(remote) {
+		return "", false
+	}
+
+	if header := strings.Join(r.Header.Values("X-Forwarded-For"), ","); header != "" {
+		hops := strings.Split(header, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return "", false
+			}
+
+			if i == 0 || !app.isTrustedProxy(addr) {
+				return addr.String(), true
+			}
+		}
+	}
+
+	addr, err := netip.ParseAddr(r.Header.Get("X-Real-IP"))
+	if err != nil {
+		return "", false
+	}
+
+	return addr.String(), true
+}
+
+func (app *application) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range app.config.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTrustedProxies parses IP addresses and CIDR ranges of trusted proxies.
+func parseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if addr, err := netip.ParseAddr(v); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
 func (app *application) rateLimiter(next http.Handler) http.Handler {
 	type client struct {
 		limiter  *rate.Limiter
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/minhnghia2k3/GOssage/internal"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,lte=255"`
+}
+
+type ResetPasswordPayload struct {
+	Password string `json:"password" validate:"required,gte=8,lte=72"`
+}
+
+// @Summary		Forgot password
+// @Description	send a password reset email if the account exists,
+// @Description	always responses 202 so the existence of the account is not leaked
+// @Tags			authentication
+// @Accept			json
+// @Produce		json
+// @Param			payload	body	ForgotPasswordPayload	true	"Account email"
+// @Success		202
+// @Failure		400	{object}	error
+// @Failure		500	{object}	error
+// @Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := app.storage.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Generate plain token
+	plainToken := uuid.New().String()
+
+	// Hash plain token for storing
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	if err = app.storage.Users.CreatePasswordReset(r.Context(), user.ID, hashToken, app.config.mail.resetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.enqueuePasswordResetEmail(r.Context(), user, plainToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary		Reset password
+// @Description	set a new password using the token from the reset email,
+// @Description	every session of the user is revoked
+// @Tags			authentication
+// @Accept			json
+// @Produce		json
+// @Param			token	path	string					true	"Password reset token"
+// @Param			payload	body	ResetPasswordPayload	true	"New password"
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/authentication/password/reset/{token} [put]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	token := chi.URLParam(r, "token")
+
+	sessionIDs, err := app.storage.Users.ResetPassword(r.Context(), token, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err = app.revokeSessionsCache(r.Context(), sessionIDs...); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// enqueuePasswordResetEmail enqueues the password reset link to user Email.
+func (app *application) enqueuePasswordResetEmail(ctx context.Context, user *store.User, token string) error {
+	resetURL := fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, token)
+
+	data := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  resetURL,
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	return app.enqueueEmail(ctx, internal.PasswordResetTemplatePath, user.Locale, []string{user.Email}, data)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/go-chi/chi/v5"
+	"github.com/minhnghia2k3/GOssage/internal/markdown"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"net/http"
 	"strconv"
@@ -11,14 +12,24 @@ import (
 )
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,lte=255"`
-	Content string   `json:"content" validate:"required,lte=255"`
+	Title string `json:"title" validate:"required,lte=255"`
+	// Content is written in Markdown
+	Content string   `json:"content" validate:"required,lte=100000"`
 	Tags    []string `json:"tags" validate:"omitempty,dive,lte=100"`
+	// Status is published by default, or scheduled if PublishAt is set
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// AttachmentIDs are IDs of images uploaded by the author
+	AttachmentIDs []int64 `json:"attachment_ids" validate:"omitempty,max=10,unique,dive,min=1"`
 }
 type UpdatePostPayload struct {
-	Title   *string  `json:"title" validate:"omitempty,lte=255"`
-	Content *string  `json:"content" validate:"omitempty,lte=255"`
-	Tags    []string `json:"tags" validate:"omitempty,dive,lte=100"`
+	Title     *string    `json:"title" validate:"omitempty,lte=255"`
+	Content   *string    `json:"content" validate:"omitempty,lte=100000"`
+	Tags      []string   `json:"tags" validate:"omitempty,dive,lte=100"`
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+	// AttachmentIDs replaces the attachments of the post, an empty list removes them
+	AttachmentIDs []int64 `json:"attachment_ids" validate:"omitempty,max=10,unique,dive,min=1"`
 }
 
 const (
@@ -26,18 +37,84 @@ const (
 	postID  string      = "postID"
 )
 
+// excerptLength is the maximum length in characters of post excerpts shown in lists
+const excerptLength = 280
+
+var (
+	errPublishAtPast     = errors.New("publish_at must be in the future")
+	errPublishAtRequired = errors.New("publish_at is required for scheduled posts")
+	errPublishAtStatus   = errors.New("publish_at is only allowed for scheduled posts")
+)
+
+// getMyPostsHandler lists posts of the authenticated user, including drafts and scheduled posts.
+// Pass next_cursor or prev_cursor of the response as cursor to get another page.
+//
+//	@Summary		List my posts
+//	@Description	list posts of the authenticated user by status, most recently created first
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			status	query	string	false	"draft, scheduled or published, any status by default"
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.PostWithMetadata
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/me/posts [get]
+func (app *application) getMyPostsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	q := store.PaginatedUserPostQuery{
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, page, err := app.storage.Posts.GetByUserID(r.Context(), user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if posts == nil {
+		posts = []store.PostWithMetadata{}
+	}
+
+	for i := range posts {
+		app.setAttachmentURLs(posts[i].Attachments)
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, posts, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // getPostHandler gets post by provided post ID,
-// then get all its comments,
-// response result with http.StatusOK
+// comments are listed by getCommentsHandler,
+// response result with http.StatusOK, or http.StatusNotModified
+// if If-None-Match holds the current ETag of the post.
 //
 //	@Summary		Get post
-//	@Description	get post by id
+//	@Description	get post by id, the ETag header holds the version of the post
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postID	path	int	true	"Post ID"
+//	@Param			postID			path	int		true	"Post ID"
+//	@Param			If-None-Match	header	string	false	"ETag of a cached copy of the post"
 //	@Security		ApiKeyAuth
 //	@Success		200	{object}	store.Post
+//	@Header			200	{string}	ETag	"version of the post"
+//	@Success		304
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -45,16 +122,17 @@ const (
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := r.Context().Value(postCtx).(*store.Post)
 
-	// fetch comments of the post
-	comments, err := app.storage.Comments.GetByPostID(context.Background(), post.ID)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	etag := versionETag(post.Version)
+	w.Header().Set("ETag", etag)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchETag(ifNoneMatch, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	post.Comments = comments
+	app.setAttachmentURLs(post.Attachments)
 
-	if err = app.jsonResponse(w, http.StatusOK, post); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
@@ -62,7 +140,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 // createPostHandler creates new post using request body data.
 //
 //	@Summary		Create a post
-//	@Description	create a new post
+//	@Description	create a new post, published now, scheduled at publish_at, or as a draft only visible to its author
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
@@ -89,42 +167,80 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	post := store.Post{
-		UserID:  user.ID,
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
+		UserID:        user.ID,
+		Title:         payload.Title,
+		Content:       payload.Content,
+		Tags:          payload.Tags,
+		AttachmentIDs: payload.AttachmentIDs,
+	}
+
+	status := payload.Status
+	if status == "" {
+		status = store.PostStatusPublished
+		if payload.PublishAt != nil {
+			status = store.PostStatusScheduled
+		}
+	}
+
+	if err = setPostStatus(&post, status, payload.PublishAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err = renderPost(&post); err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
 	err = app.storage.Posts.Create(context.Background(), &post)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrUnknownAttachment):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	app.setAttachmentURLs(post.Attachments)
+
 	if err = app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-// updatePostHandler updates an existing post with provided data
+// updatePostHandler updates an existing post with provided data.
+// Clients send the ETag they read as If-Match, so edits made meanwhile by
+// someone else are not overwritten.
 //
 //	@Summary		Update post
-//	@Description	update post by id
+//	@Description	update post by id, without If-Match responses 428, if the post changed since the If-Match ETag
+//	@Description	responses 412, if it changes during the update responses 409
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			postID	path	int					true	"Post ID"
-//	@Param			post	body	UpdatePostPayload	true	"Update post"
+//	@Param			postID		path	int					true	"Post ID"
+//	@Param			If-Match	header	string				true	"ETag of the edited version of the post"
+//	@Param			post		body	UpdatePostPayload	true	"Update post"
 //	@Security		ApiKeyAuth
 //	@Success		200	{object}	store.Post
+//	@Header			200	{string}	ETag	"new version of the post"
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		412	{object}	error
+//	@Failure		428	{object}	error
 //	@Failure		500	{object}	error
 //	@Router			/posts/{postID} [patch]
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	var payload UpdatePostPayload
 	post := r.Context().Value(postCtx).(*store.Post)
 
+	if !app.checkIfMatch(w, r, post.Version) {
+		return
+	}
+
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -140,23 +256,54 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
+
+		if err := renderPost(post); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
 	}
 	if payload.Tags != nil {
 		post.Tags = payload.Tags
 	}
-	post.UpdatedAt = time.Now()
+	if payload.AttachmentIDs != nil {
+		post.AttachmentIDs = payload.AttachmentIDs
+	}
+	if payload.Status != nil || payload.PublishAt != nil {
+		// Setting only publish_at reschedules the post
+		status := store.PostStatusScheduled
+		if payload.Status != nil {
+			status = *payload.Status
+		}
 
-	if err := app.storage.Posts.Update(context.Background(), post); err != nil {
-		app.internalServerError(w, r, err)
+		if err := setPostStatus(post, status, payload.PublishAt); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	// The version is checked again by the update, in case the post changed since it was read
+	if err := app.storage.Posts.Update(r.Context(), post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictResponse(w, r, errEditConflict)
+		case errors.Is(err, store.ErrUnknownAttachment):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", versionETag(post.Version))
+	app.setAttachmentURLs(post.Attachments)
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-// deletePostHandler delete a post by given post ID.
+// deletePostHandler moves a post to the trash by given post ID,
+// admins can restore it until the retention period ends.
 //
 //	@Summary		Delete post
 //	@Description	delete post by id
@@ -191,6 +338,58 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// renderPost renders the Markdown content of the post into sanitised HTML and its excerpt.
+func renderPost(post *store.Post) error {
+	contentHTML, err := markdown.Render(post.Content)
+	if err != nil {
+		return err
+	}
+
+	post.ContentHTML = contentHTML
+	post.Excerpt = markdown.Excerpt(contentHTML, excerptLength)
+
+	return nil
+}
+
+// setPostStatus moves the post to status. Scheduled posts are published at publishAt
+// by the scheduler, newly published posts are published now.
+func setPostStatus(post *store.Post, status string, publishAt *time.Time) error {
+	now := time.Now()
+
+	switch status {
+	case store.PostStatusScheduled:
+		if publishAt == nil {
+			if post.Status != store.PostStatusScheduled {
+				return errPublishAtRequired
+			}
+			publishAt = post.PublishAt
+		}
+
+		if !publishAt.After(now) {
+			return errPublishAtPast
+		}
+
+		post.PublishAt = publishAt
+	case store.PostStatusPublished:
+		if publishAt != nil {
+			return errPublishAtStatus
+		}
+
+		if post.Status != store.PostStatusPublished {
+			post.PublishAt = &now
+		}
+	default:
+		if publishAt != nil {
+			return errPublishAtStatus
+		}
+
+		post.PublishAt = nil
+	}
+
+	post.Status = status
+	return nil
+}
+
 // parseID parse given key into base64 integer.
 func parseID(r *http.Request, key string) (int64, error) {
 	id, err := strconv.ParseInt(chi.URLParam(r, key), 10, 64)
@@ -224,3 +423,30 @@ func (app *application) getPostMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// checkPostVisibility hides posts of private accounts from users who do not follow them,
+// and unpublished posts from everyone but their author.
+func (app *application) checkPostVisibility(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromContext(r)
+		post := r.Context().Value(postCtx).(*store.Post)
+
+		if post.Status != store.PostStatusPublished && post.UserID != user.ID {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		visible, err := app.storage.Followers.CanView(r.Context(), post.UserID, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
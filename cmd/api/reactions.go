@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+type ReactPayload struct {
+	Kind string `json:"kind" validate:"required,oneof=like love haha wow sad angry"`
+}
+
+// reactToPostHandler reacts to a post, replacing the previous reaction of the user.
+//
+//	@Summary		React to post
+//	@Description	react to a post with like, love, haha, wow, sad or angry
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path	int				true	"Post ID"
+//	@Param			reaction	body	ReactPayload	true	"Reaction"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/reactions [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ReactPayload
+
+	user := getUserFromContext(r)
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Users blocking each other cannot react to posts of the other
+	blocked, err := app.storage.Restrictions.IsBlocked(r.Context(), user.ID, post.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err = app.storage.Reactions.Set(r.Context(), post.ID, user.ID, payload.Kind); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeReactionHandler removes the reaction of the user from a post.
+//
+//	@Summary		Remove reaction
+//	@Description	remove the reaction of the authenticated user from a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/reactions [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	if err := app.storage.Reactions.Delete(r.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
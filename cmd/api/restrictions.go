@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+var errRestrictSelf = errors.New("cannot block or mute yourself")
+
+// @Summary		Block user
+// @Description	block the user, follows between both users are removed
+// @Description	and neither can follow or comment on posts of the other
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"User ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.restrictUser(w, r, app.storage.Restrictions.Block)
+}
+
+// @Summary		Unblock user
+// @Description	unblock the user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"User ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/{userID}/block [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.restrictUser(w, r, app.storage.Restrictions.Unblock)
+}
+
+// @Summary		Mute user
+// @Description	hide posts of the user from the feed of the authenticated user
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"User ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.restrictUser(w, r, app.storage.Restrictions.Mute)
+}
+
+// @Summary		Unmute user
+// @Description	show posts of the user in the feed again
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			userID	path	int	true	"User ID"
+// @Security		ApiKeyAuth
+// @Success		204
+// @Failure		400	{object}	error
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/{userID}/mute [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.restrictUser(w, r, app.storage.Restrictions.Unmute)
+}
+
+type restrictFunc func(ctx context.Context, userID, targetID int64) error
+
+func (app *application) restrictUser(w http.ResponseWriter, r *http.Request, restrict restrictFunc) {
+	user := getUserFromContext(r)
+
+	targetID, err := parseID(r, "userID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if targetID == user.ID {
+		app.badRequestResponse(w, r, errRestrictSelf)
+		return
+	}
+
+	if err = restrict(r.Context(), user.ID, targetID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
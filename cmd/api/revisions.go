@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/minhnghia2k3/GOssage/internal/diff"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+	"strconv"
+)
+
+// PostDiff compares two versions of a post line by line.
+type PostDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+}
+
+// getPostRevisionsHandler lists past versions of a post, latest first.
+// Pass next_cursor or prev_cursor of the response as cursor to get another page.
+//
+//	@Summary		List post revisions
+//	@Description	list past versions of a post, saved by each update
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int		true	"Post ID"
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.PostRevision
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/revisions [get]
+func (app *application) getPostRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := r.Context().Value(postCtx).(*store.Post)
+
+	q := store.PaginatedRevisionQuery{
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	revisions, page, err := app.storage.Posts.GetRevisions(r.Context(), post.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []store.PostRevision{}
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, revisions, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getPostDiffHandler compares two versions of a post, either past revisions or the current version.
+//
+//	@Summary		Diff post versions
+//	@Description	compare title and content of two versions of a post line by line
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path	int	true	"Post ID"
+//	@Param			from	query	int	true	"version to compare from"
+//	@Param			to		query	int	false	"version to compare to, the current version by default"
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	PostDiff
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		422	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/posts/{postID}/revisions/diff [get]
+func (app *application) getPostDiffHandler(w http.ResponseWriter, r *http.Request) {
+	post := r.Context().Value(postCtx).(*store.Post)
+	qr := r.URL.Query()
+
+	params := struct {
+		From int `validate:"min=0"`
+		To   int `validate:"min=0"`
+	}{
+		To: post.Version,
+	}
+
+	var err error
+	if params.From, err = strconv.Atoi(qr.Get("from")); err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid from version %q", qr.Get("from")))
+		return
+	}
+
+	if to := qr.Get("to"); to != "" {
+		if params.To, err = strconv.Atoi(to); err != nil {
+			app.badRequestResponse(w, r, fmt.Errorf("invalid to version %q", to))
+			return
+		}
+	}
+
+	if err = Validate.Struct(params); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	from, err := app.postVersion(r, post, params.From)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	to, err := app.postVersion(r, post, params.To)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	d := PostDiff{
+		From: from.Version,
+		To:   to.Version,
+	}
+
+	if d.Title, err = diff.Lines(from.Title, to.Title); err == nil {
+		d.Content, err = diff.Lines(from.Content, to.Content)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, diff.ErrTooManyEdits):
+			app.unprocessableEntityResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusOK, d); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// postVersion returns the given version of the post, from its revisions unless it is the current one.
+func (app *application) postVersion(r *http.Request, post *store.Post, version int) (*store.PostRevision, error) {
+	if version == post.Version {
+		return &store.PostRevision{
+			PostID:    post.ID,
+			Version:   post.Version,
+			Title:     post.Title,
+			Content:   post.Content,
+			Tags:      post.Tags,
+			CreatedAt: post.UpdatedAt,
+		}, nil
+	}
+
+	return app.storage.Posts.GetRevision(r.Context(), post.ID, version)
+}
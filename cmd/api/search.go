@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+)
+
+// searchHandler searches posts, comments and users, best matches first.
+//
+//	@Summary		Search
+//	@Description	full-text search of posts, comments and users, tolerating typos.
+//	@Description	q supports web search syntax: "quoted phrase", or, -excluded.
+//	@Description	Posts and comments of private accounts are only found by their followers
+//	@Tags			search
+//	@Accept			json
+//	@Produce		json
+//	@Param			q		query	string	true	"search query"
+//	@Param			type	query	string	false	"all (default), post, comment or user"
+//	@Param			limit	query	int		false	"limit"
+//	@Param			offset	query	int		false	"offset"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.SearchResult
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	q := store.PaginatedSearchQuery{
+		Type:  "all",
+		Limit: 20,
+	}
+
+	if err := q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	results, err := app.storage.Search.Search(r.Context(), user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if results == nil {
+		results = []store.SearchResult{}
+	}
+
+	if err = app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"net/http"
+	"time"
+)
+
+const sessionCtx contextType = "session"
+
+type SessionWithCurrent struct {
+	store.Session
+	Current bool `json:"current"`
+}
+
+// getSessionsHandler lists active sessions of the authenticated user.
+//
+//	@Summary		List sessions
+//	@Description	list active sessions of the authenticated user
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		SessionWithCurrent
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	currentID := getSessionIDFromContext(r)
+
+	sessions, err := app.storage.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	response := make([]SessionWithCurrent, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionWithCurrent{
+			Session: session,
+			Current: session.ID == currentID,
+		})
+	}
+
+	if err = app.jsonResponse(w, http.StatusOK, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// revokeSessionHandler revokes a session of the authenticated user,
+// revoking the current session logs the user out.
+//
+//	@Summary		Revoke session
+//	@Description	revoke a session of the authenticated user
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Param			sessionID	path	string	true	"Session ID"
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	sessionID := chi.URLParam(r, "sessionID")
+	if err := uuid.Validate(sessionID); err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+
+	err := app.storage.Sessions.Revoke(r.Context(), user.ID, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err = app.revokeSessionsCache(r.Context(), sessionID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessionsHandler revokes every session of the authenticated user.
+//
+//	@Summary		Revoke all sessions
+//	@Description	revoke every session of the authenticated user
+//	@Tags			sessions
+//	@Accept			json
+//	@Produce		json
+//	@Security		ApiKeyAuth
+//	@Success		204
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/sessions [delete]
+func (app *application) revokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	if err := app.revokeAllSessions(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessions revokes every session of the user in database and revocation set.
+func (app *application) revokeAllSessions(ctx context.Context, userID int64) error {
+	ids, err := app.storage.Sessions.RevokeAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return app.revokeSessionsCache(ctx, ids...)
+}
+
+// isSessionRevoked checks revocation set in Redis if enabled, otherwise in database.
+// The database is only queried while Redis is unavailable.
+func (app *application) isSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if !app.config.redisConfig.enabled {
+		return app.storage.Sessions.IsRevoked(ctx, sessionID)
+	}
+
+	revoked, err := app.cacheStorage.Sessions.IsRevoked(ctx, sessionID)
+	if err != nil {
+		app.logger.Infow("error reading revocation set", "session_id", sessionID, "error", err)
+		return app.storage.Sessions.IsRevoked(ctx, sessionID)
+	}
+
+	return revoked, nil
+}
+
+// backfillRevocationSet adds sessions revoked in database to Redis revocation set if enabled,
+// so revocations survive a flush of Redis. Only sessions whose access tokens may still be valid
+// are added, until their tokens expire.
+func (app *application) backfillRevocationSet(ctx context.Context) error {
+	if !app.config.redisConfig.enabled {
+		return nil
+	}
+
+	exp := app.config.auth.token.exp
+	sessions, err := app.storage.Sessions.GetRevokedSince(ctx, time.Now().Add(-exp))
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		ttl := time.Until(session.RevokedAt.Add(exp))
+		if ttl <= 0 {
+			continue
+		}
+
+		if err = app.cacheStorage.Sessions.Revoke(ctx, session.ID, ttl); err != nil {
+			return err
+		}
+	}
+
+	app.logger.Infow("revocation set backfilled", "sessions", len(sessions))
+
+	return nil
+}
+
+// revokeSessionsCache adds sessions to Redis revocation set if enabled,
+// entries expire together with the access tokens of the sessions.
+func (app *application) revokeSessionsCache(ctx context.Context, sessionIDs ...string) error {
+	if !app.config.redisConfig.enabled {
+		return nil
+	}
+
+	for _, id := range sessionIDs {
+		if err := app.cacheStorage.Sessions.Revoke(ctx, id, app.config.auth.token.exp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSessionIDFromContext(r *http.Request) string {
+	return r.Context().Value(sessionCtx).(string)
+}
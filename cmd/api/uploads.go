@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/minhnghia2k3/GOssage/internal/media"
+	"github.com/minhnghia2k3/GOssage/internal/store"
+	"io"
+	"net/http"
+)
+
+// multipartOverhead is the room left for multipart headers and boundaries
+// on top of the uploaded file.
+const multipartOverhead = 64 << 10 // 64 KiB
+
+var (
+	errMissingFile  = errors.New(`multipart body must contain a "file" part`)
+	errFileTooLarge = errors.New("file too large")
+)
+
+// uploadHandler stores an uploaded image, which can be attached to posts by its ID.
+// Uploads not attached to any post are deleted after a while.
+//
+//	@Summary		Upload an image
+//	@Description	upload a JPEG, PNG or GIF image as the "file" part of a multipart form,
+//	@Description	its metadata such as EXIF is stripped. Attach it with attachment_ids of a post.
+//	@Tags			uploads
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//	@Security		ApiKeyAuth
+//	@Success		201	{object}	store.Attachment
+//	@Failure		400	{object}	error
+//	@Failure		413	{object}	error
+//	@Failure		415	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/uploads [post]
+func (app *application) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	maxBytes := app.config.uploads.maxBytes
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+
+	data, err := readUploadedFile(r, maxBytes)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError), errors.Is(err, errFileTooLarge):
+			app.payloadTooLargeResponse(w, r, fmt.Errorf("file must not be larger than %d bytes", maxBytes))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	img, err := media.ParseImage(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case errors.Is(err, media.ErrInvalidImage):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	key := uuid.New().String() + img.Ext
+
+	if err = app.objects.Put(r.Context(), key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	attachment := store.Attachment{
+		UserID:      user.ID,
+		Key:         key,
+		URL:         app.objects.URL(key),
+		ContentType: img.ContentType,
+		Size:        int64(len(img.Data)),
+		Width:       img.Width,
+		Height:      img.Height,
+	}
+
+	if err = app.storage.Attachments.Create(r.Context(), &attachment); err != nil {
+		// Nothing references the file yet, so it is safe to delete
+		if err := app.objects.Delete(r.Context(), key); err != nil {
+			app.logger.Infow("error deleting upload", "key", key, "error", err)
+		}
+
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err = app.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setAttachmentURLs builds URLs of attachments from their storage keys,
+// so they follow the configured object store.
+func (app *application) setAttachmentURLs(attachments []store.Attachment) {
+	for i := range attachments {
+		attachments[i].URL = app.objects.URL(attachments[i].Key)
+	}
+}
+
+// readUploadedFile reads the "file" part of the multipart request body,
+// returns errFileTooLarge if it is larger than maxBytes.
+func readUploadedFile(r *http.Request, maxBytes int64) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errMissingFile
+			}
+			return nil, err
+		}
+
+		if part.FormName() != "file" {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if int64(len(data)) > maxBytes {
+			return nil, errFileTooLarge
+		}
+
+		return data, nil
+	}
+}
@@ -1,37 +1,40 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"net/http"
-	"strconv"
 )
 
-// getUserHandler gets user by id
+// getUserHandler gets public profile of user by id
 //
 //	@Summary		Get user
-//	@Description	get user by given id
+//	@Description	get user profile by given id, with follower, following and post counts
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			userID	path	int	true	"User ID"
 //	@Security		ApiKeyAuth
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	store.UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
 //	@Router			/users/{userID} [get]
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
-	param := chi.URLParam(r, "userID")
+	viewer := getUserFromContext(r)
 
-	userID, err := strconv.ParseInt(param, 10, 64)
+	userID, err := parseID(r, "userID")
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	user, err := app.getUser(r.Context(), userID)
+	profile, err := app.storage.Users.GetProfile(r.Context(), userID, viewer.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -42,20 +45,194 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = app.jsonResponse(w, http.StatusOK, user); err != nil {
+	if err = app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type UpdateSettingsPayload struct {
+	Locale    *string `json:"locale" validate:"omitempty,bcp47_language_tag,lte=10"`
+	IsPrivate *bool   `json:"is_private"`
+}
+
+// updateSettingsHandler updates settings of the authenticated user.
+//
+//	@Summary		Update settings
+//	@Description	update email locale and privacy of the authenticated user,
+//	@Description	turning the account public accepts its pending follow requests
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	UpdateSettingsPayload	true	"Settings"
+//	@Security		ApiKeyAuth
+//	@Success		200	{object}	store.User
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/me [patch]
+func (app *application) updateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateSettingsPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Copy the user, it may be shared with the cache
+	user := *getUserFromContext(r)
+
+	if payload.Locale != nil {
+		user.Locale = app.templates.Match(*payload.Locale)
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.storage.Users.UpdateSettings(r.Context(), &user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.redisConfig.enabled {
+		if err := app.cacheStorage.Users.Delete(r.Context(), user.ID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getFollowersHandler lists users following the user, most recent first.
+//
+//	@Summary		List followers
+//	@Description	list followers of the user page by page
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path	int		true	"User ID"
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.FollowUser
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.storage.Followers.GetFollowers)
+}
+
+// getFollowingHandler lists users followed by the user, most recent first.
+//
+//	@Summary		List following
+//	@Description	list users followed by the user page by page
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path	int		true	"User ID"
+//	@Param			cursor	query	string	false	"next_cursor or prev_cursor of another page"
+//	@Param			limit	query	int		false	"limit"
+//	@Security		ApiKeyAuth
+//	@Success		200	{array}		store.FollowUser
+//	@Header			200	{string}	Link	"URLs of the next and previous pages"
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.storage.Followers.GetFollowing)
+}
+
+type followListFunc func(ctx context.Context, userID, viewerID int64, q store.PaginatedFollowQuery) ([]store.FollowUser, store.Page, error)
+
+// listFollows responds a page of the follow list of userID.
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, list followListFunc) {
+	viewer := getUserFromContext(r)
+
+	userID, err := parseID(r, "userID")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	q := store.PaginatedFollowQuery{
+		Limit: 20,
+	}
+
+	if err = q.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err = Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if _, err = app.getUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// Connections of private accounts are visible to their followers only
+	visible, err := app.storage.Followers.CanView(r.Context(), userID, viewer.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	users, page, err := list(r.Context(), userID, viewer.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if users == nil {
+		users = []store.FollowUser{}
+	}
+
+	if err = app.paginatedJSONResponse(w, r, http.StatusOK, users, page); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
 // @Summary		Follow user
-// @Description	authenticated user follow provided user
+// @Description	authenticated user follow provided user,
+// @Description	following a private account creates a follow request answered with 202
 // @Tags			users
 // @Accept			json
 // @Produce		json
 // @Param			userID	path	int	true	"User ID"
 // @Security		ApiKeyAuth
+// @Success		202
 // @Success		204
 // @Failure		400	{object}	error
+// @Failure		403	{object}	error
 // @Failure		409	{object}	error
 // @Failure		404	{object}	error
 // @Failure		500	{object}	error
@@ -69,11 +246,15 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.storage.Followers.Follow(r.Context(), followerUser.ID, followedID)
+	requested, err := app.storage.Followers.Follow(r.Context(), followerUser.ID, followedID)
 	if err != nil {
 		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
 		case errors.Is(err, store.ErrConflict):
 			app.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrBlocked):
+			app.forbiddenResponse(w, r)
 		case errors.Is(err, store.ErrFollowSelf):
 			app.badRequestResponse(w, r, err)
 		default:
@@ -82,11 +263,16 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if requested {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // @Summary		Unfollow user
-// @Description	authenticated user unfollow provided user
+// @Description	authenticated user unfollow provided user, or cancel the follow request
 // @Tags			users
 // @Accept			json
 // @Produce		json
@@ -146,6 +332,63 @@ func (app *application) activeUserHandler(w http.ResponseWriter, r *http.Request
 	w.WriteHeader(http.StatusNoContent)
 }
 
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,lte=255"`
+}
+
+// @Summary		Resend activation email
+// @Description	rotate activation token of an inactive user and send the email again,
+// @Description	unknown or already active emails, and emails resent within the cooldown, are accepted
+// @Description	without sending anything, so the existence of pending accounts is not leaked
+// @Tags			users
+// @Accept			json
+// @Produce		json
+// @Param			payload	body	ResendActivationPayload	true	"Account email"
+// @Success		202
+// @Failure		400	{object}	error
+// @Failure		500	{object}	error
+// @Router			/users/activate/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Generate plain token
+	plainToken := uuid.New().String()
+
+	// Hash plain token for storing
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	_, err := app.storage.Users.ResendInvitation(
+		r.Context(),
+		payload.Email,
+		hashToken,
+		app.config.mail.exp,
+		app.config.mail.resendCooldown,
+		app.invitationEmail(plainToken),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrTooManyRequests):
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
 func getUserFromContext(r *http.Request) *store.User {
 	return r.Context().Value(userCtx).(*store.User)
 }
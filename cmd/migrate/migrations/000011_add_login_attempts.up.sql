@@ -0,0 +1,16 @@
+ALTER TABLE users
+    ADD COLUMN failed_login_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_failed_login_at  timestamptz,
+    ADD COLUMN locked_until          timestamptz;
+
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    id         bigserial PRIMARY KEY,
+    user_id    bigint,
+    ip_address varchar(45) NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address, created_at);
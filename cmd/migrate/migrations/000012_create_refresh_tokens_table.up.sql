@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id         bigserial PRIMARY KEY,
+    user_id    bigint      NOT NULL,
+    family_id  uuid        NOT NULL,
+    token      bytea       NOT NULL UNIQUE,
+    expiry     timestamptz NOT NULL,
+    used_at    timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id           uuid PRIMARY KEY,
+    user_id      bigint      NOT NULL,
+    user_agent   text        NOT NULL DEFAULT '',
+    ip_address   varchar(45) NOT NULL DEFAULT '',
+    expiry       timestamptz NOT NULL,
+    revoked_at   timestamptz,
+    last_used_at timestamptz DEFAULT now(),
+    created_at   timestamptz DEFAULT now(),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+-- Every refresh token family issued before becomes a session
+INSERT INTO sessions (id, user_id, expiry, revoked_at, created_at)
+SELECT family_id, user_id, MAX(expiry), MAX(revoked_at), MIN(created_at)
+FROM refresh_tokens
+GROUP BY family_id, user_id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT fk_refresh_tokens_sessions FOREIGN KEY (family_id) REFERENCES sessions (id) ON DELETE CASCADE;
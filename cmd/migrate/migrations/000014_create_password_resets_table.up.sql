@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_reset
+(
+    user_id bigint      NOT NULL,
+    token   bytea       NOT NULL,
+    expiry  timestamptz NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_token ON password_reset (token);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_users_unactivated;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS invited_at;
+
+DROP INDEX IF EXISTS idx_user_invitation_expiry;
+
+ALTER TABLE user_invitation
+    DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,15 @@
+ALTER TABLE user_invitation
+    ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_user_invitation_expiry ON user_invitation (expiry);
+
+-- When the latest activation email was sent, the janitor counts its grace period from it.
+-- Users with a pending invitation get a full grace period from now.
+ALTER TABLE users
+    ADD COLUMN invited_at timestamptz;
+
+UPDATE users u
+SET invited_at = coalesce((SELECT max(ui.created_at) FROM user_invitation ui WHERE ui.user_id = u.id), u.created_at)
+WHERE u.is_active = false;
+
+CREATE INDEX IF NOT EXISTS idx_users_unactivated ON users (invited_at) WHERE is_active = false;
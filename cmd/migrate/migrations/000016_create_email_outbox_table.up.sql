@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS email_outbox
+(
+    id              bigserial PRIMARY KEY,
+    template        varchar(255) NOT NULL,
+    recipients      text[]       NOT NULL,
+    data            jsonb        NOT NULL DEFAULT '{}',
+    status          varchar(20)  NOT NULL DEFAULT 'pending', -- pending, sent, dead
+    attempts        int          NOT NULL DEFAULT 0,
+    last_error      text,
+    next_attempt_at timestamptz  NOT NULL DEFAULT now(),
+    locked_until    timestamptz,
+    sent_at         timestamptz,
+    created_at      timestamptz DEFAULT now(),
+    updated_at      timestamptz DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_status_next_attempt_at ON email_outbox (status, next_attempt_at);
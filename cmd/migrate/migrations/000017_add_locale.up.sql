@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN locale varchar(10) NOT NULL DEFAULT 'en';
+
+ALTER TABLE email_outbox
+    ADD COLUMN locale varchar(10) NOT NULL DEFAULT 'en';
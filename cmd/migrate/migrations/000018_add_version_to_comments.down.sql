@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS version;
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS updated_at;
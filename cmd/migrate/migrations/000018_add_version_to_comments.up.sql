@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE comments
+    ADD COLUMN version int NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+DROP INDEX IF EXISTS idx_comments_post_id_parent_id_created_at;
+
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS depth;
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS parent_id;
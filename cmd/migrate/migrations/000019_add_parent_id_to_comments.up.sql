@@ -0,0 +1,8 @@
+ALTER TABLE comments
+    ADD COLUMN parent_id bigint REFERENCES comments (id) ON DELETE CASCADE;
+ALTER TABLE comments
+    ADD COLUMN depth int NOT NULL DEFAULT 0;
+
+-- Threads are listed page by page, replies of a comment are counted by parent_id
+CREATE INDEX IF NOT EXISTS idx_comments_post_id_parent_id_created_at ON comments (post_id, parent_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_id ON comments (parent_id);
@@ -0,0 +1,37 @@
+-- 'simple' configuration does not stem words, so it works for both English and Vietnamese content
+ALTER TABLE posts
+    ADD COLUMN search_vector tsvector;
+
+-- array_to_string is not immutable, so the posts vector is maintained by a trigger
+-- instead of a generated column
+CREATE OR REPLACE FUNCTION posts_search_vector_update() RETURNS trigger AS
+$$
+BEGIN
+    NEW.search_vector :=
+            setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+            setweight(to_tsvector('simple', coalesce(array_to_string(NEW.tags, ' '), '')), 'B') ||
+            setweight(to_tsvector('simple', coalesce(NEW.content, '')), 'C');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_posts_search_vector
+    BEFORE INSERT OR UPDATE OF title, content, tags
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_search_vector_update();
+
+UPDATE posts
+SET search_vector = setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+                    setweight(to_tsvector('simple', coalesce(array_to_string(tags, ' '), '')), 'B') ||
+                    setweight(to_tsvector('simple', coalesce(content, '')), 'C');
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector);
+
+ALTER TABLE comments
+    ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING gin (search_vector);
+
+-- Trigram index for typo tolerant username search, idx_users_username only serves equality
+CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+
+DROP TRIGGER IF EXISTS trg_posts_count ON posts;
+DROP FUNCTION IF EXISTS posts_count_update();
+
+DROP TRIGGER IF EXISTS trg_followers_count ON followers;
+DROP FUNCTION IF EXISTS followers_count_update();
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS posts_count;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS followers_count;
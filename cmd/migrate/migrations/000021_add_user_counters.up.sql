@@ -0,0 +1,62 @@
+ALTER TABLE users
+    ADD COLUMN followers_count bigint NOT NULL DEFAULT 0;
+ALTER TABLE users
+    ADD COLUMN following_count bigint NOT NULL DEFAULT 0;
+ALTER TABLE users
+    ADD COLUMN posts_count bigint NOT NULL DEFAULT 0;
+
+-- Counters are maintained by triggers in the same transaction as the change,
+-- the row lock taken by UPDATE serializes concurrent follows of the same user
+CREATE OR REPLACE FUNCTION followers_count_update() RETURNS trigger AS
+$$
+BEGIN
+    -- Lock both users in id order, so A following B while B follows A cannot deadlock
+    PERFORM 1
+    FROM users
+    WHERE id IN (coalesce(NEW.user_id, OLD.user_id), coalesce(NEW.follower_id, OLD.follower_id))
+    ORDER BY id
+    FOR UPDATE;
+
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET followers_count = followers_count + 1 WHERE id = NEW.user_id;
+        UPDATE users SET following_count = following_count + 1 WHERE id = NEW.follower_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET followers_count = followers_count - 1 WHERE id = OLD.user_id;
+        UPDATE users SET following_count = following_count - 1 WHERE id = OLD.follower_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_followers_count
+    AFTER INSERT OR DELETE
+    ON followers
+    FOR EACH ROW
+EXECUTE FUNCTION followers_count_update();
+
+CREATE OR REPLACE FUNCTION posts_count_update() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_posts_count
+    AFTER INSERT OR DELETE
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_count_update();
+
+UPDATE users u
+SET followers_count = (SELECT COUNT(*) FROM followers f WHERE f.user_id = u.id),
+    following_count = (SELECT COUNT(*) FROM followers f WHERE f.follower_id = u.id),
+    posts_count     = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id);
+
+-- Followers and following lists are paginated by follow date
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at);
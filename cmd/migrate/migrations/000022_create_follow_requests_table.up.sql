@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN is_private boolean NOT NULL DEFAULT false;
+
+-- Pending requests to follow a private account, accepted requests become followers rows
+CREATE TABLE IF NOT EXISTS follow_requests
+(
+    user_id      bigint NOT NULL,
+    requester_id bigint NOT NULL,
+    created_at   timestamptz DEFAULT now(),
+
+    PRIMARY KEY (user_id, requester_id),
+
+    CONSTRAINT chk_follow_request_self CHECK (user_id <> requester_id),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+
+    FOREIGN KEY (requester_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_created_at ON follow_requests (user_id, created_at);
@@ -0,0 +1,20 @@
+-- Users blocked or muted by user_id
+CREATE TABLE IF NOT EXISTS user_restrictions
+(
+    user_id    bigint      NOT NULL,
+    target_id  bigint      NOT NULL,
+    kind       varchar(10) NOT NULL, -- block, mute
+    created_at timestamptz DEFAULT now(),
+
+    PRIMARY KEY (user_id, target_id, kind),
+
+    CONSTRAINT chk_user_restriction_self CHECK (user_id <> target_id),
+    CONSTRAINT chk_user_restriction_kind CHECK (kind IN ('block', 'mute')),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+
+    FOREIGN KEY (target_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Blocks are checked in both directions
+CREATE INDEX IF NOT EXISTS idx_user_restrictions_target_id ON user_restrictions (target_id, user_id);
@@ -0,0 +1,18 @@
+-- One reaction per user and post, reacting again replaces the kind
+CREATE TABLE IF NOT EXISTS post_reactions
+(
+    post_id    bigint      NOT NULL,
+    user_id    bigint      NOT NULL,
+    kind       varchar(10) NOT NULL, -- like, love, haha, wow, sad, angry
+    created_at timestamptz DEFAULT now(),
+
+    PRIMARY KEY (post_id, user_id),
+
+    CONSTRAINT chk_post_reaction_kind CHECK (kind IN ('like', 'love', 'haha', 'wow', 'sad', 'angry')),
+
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id ON post_reactions (user_id);
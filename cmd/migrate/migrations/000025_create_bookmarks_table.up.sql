@@ -0,0 +1,18 @@
+-- Posts saved by users, removed with the post
+CREATE TABLE IF NOT EXISTS bookmarks
+(
+    user_id    bigint      NOT NULL,
+    post_id    bigint      NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (user_id, post_id),
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+-- Bookmarks of a user, most recently saved first
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id_created_at ON bookmarks (user_id, created_at DESC, post_id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_post_id ON bookmarks (post_id);
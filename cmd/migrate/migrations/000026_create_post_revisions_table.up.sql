@@ -0,0 +1,14 @@
+-- Past versions of posts, saved by each update
+CREATE TABLE IF NOT EXISTS post_revisions
+(
+    post_id    bigint       NOT NULL,
+    version    int          NOT NULL,
+    title      varchar(255) NOT NULL,
+    content    text         NOT NULL,
+    tags       varchar(100)[],
+    created_at timestamptz  NOT NULL, -- when the version was saved
+
+    PRIMARY KEY (post_id, version),
+
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
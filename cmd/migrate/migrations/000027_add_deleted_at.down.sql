@@ -0,0 +1,27 @@
+CREATE OR REPLACE FUNCTION posts_count_update() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_posts_count ON posts;
+
+CREATE TRIGGER trg_posts_count
+    AFTER INSERT OR DELETE
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_count_update();
+
+UPDATE users u
+SET posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id);
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,33 @@
+-- Deleted posts and users stay in the trash until purged by the retention job
+ALTER TABLE posts
+    ADD COLUMN deleted_at timestamptz;
+ALTER TABLE users
+    ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Posts in the trash are not counted, purging them must not count them twice
+CREATE OR REPLACE FUNCTION posts_count_update() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_posts_count ON posts;
+
+CREATE TRIGGER trg_posts_count
+    AFTER INSERT OR DELETE OR UPDATE OF deleted_at
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_count_update();
@@ -0,0 +1,35 @@
+CREATE OR REPLACE FUNCTION posts_count_update() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'UPDATE' AND OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_posts_count ON posts;
+
+CREATE TRIGGER trg_posts_count
+    AFTER INSERT OR DELETE OR UPDATE OF deleted_at
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_count_update();
+
+DROP INDEX IF EXISTS idx_posts_user_id_status;
+DROP INDEX IF EXISTS idx_posts_scheduled;
+DROP INDEX IF EXISTS idx_posts_publish_at;
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS status;
+
+UPDATE users u
+SET posts_count = (SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.deleted_at IS NULL);
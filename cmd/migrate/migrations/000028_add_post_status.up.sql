@@ -0,0 +1,53 @@
+-- Drafts are only visible to their author, scheduled posts are published
+-- by the scheduler once publish_at is due
+ALTER TABLE posts
+    ADD COLUMN status varchar(10) NOT NULL DEFAULT 'published';
+ALTER TABLE posts
+    ADD COLUMN publish_at timestamptz;
+ALTER TABLE posts
+    ADD CONSTRAINT chk_posts_status CHECK (status IN ('draft', 'scheduled', 'published'));
+
+-- Existing posts were published when they were created, backfilled before the check is added
+UPDATE posts
+SET publish_at = created_at;
+
+ALTER TABLE posts
+    ADD CONSTRAINT chk_posts_publish_at CHECK (status = 'draft' OR publish_at IS NOT NULL);
+
+-- The feed is ordered by publication time
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at DESC, id DESC) WHERE status = 'published';
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_status ON posts (user_id, status, created_at);
+
+-- Only published posts out of the trash are counted
+CREATE OR REPLACE FUNCTION posts_count_update() RETURNS trigger AS
+$$
+DECLARE
+    delta int := 0;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        IF OLD.deleted_at IS NULL AND OLD.status = 'published' THEN
+            delta := delta - 1;
+        END IF;
+    END IF;
+
+    IF TG_OP <> 'DELETE' THEN
+        IF NEW.deleted_at IS NULL AND NEW.status = 'published' THEN
+            delta := delta + 1;
+        END IF;
+    END IF;
+
+    IF delta <> 0 THEN
+        UPDATE users SET posts_count = posts_count + delta WHERE id = coalesce(NEW.user_id, OLD.user_id);
+    END IF;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_posts_count ON posts;
+
+CREATE TRIGGER trg_posts_count
+    AFTER INSERT OR DELETE OR UPDATE OF deleted_at, status
+    ON posts
+    FOR EACH ROW
+EXECUTE FUNCTION posts_count_update();
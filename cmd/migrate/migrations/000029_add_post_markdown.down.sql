@@ -0,0 +1,8 @@
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS excerpt;
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS content_html;
+
+-- Posts longer than 255 characters are truncated
+ALTER TABLE posts
+    ALTER COLUMN content TYPE varchar(255) USING left(content, 255);
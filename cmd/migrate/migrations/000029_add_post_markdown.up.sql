@@ -0,0 +1,12 @@
+-- Content is Markdown, rendered to sanitised HTML and a plain text excerpt when the post is saved
+ALTER TABLE posts
+    ALTER COLUMN content TYPE text;
+ALTER TABLE posts
+    ADD COLUMN content_html text NOT NULL DEFAULT '';
+ALTER TABLE posts
+    ADD COLUMN excerpt text NOT NULL DEFAULT '';
+
+-- Existing posts are plain text, shorter than an excerpt
+UPDATE posts
+SET content_html = '<p>' || replace(replace(replace(replace(content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;') || '</p>',
+    excerpt      = content;
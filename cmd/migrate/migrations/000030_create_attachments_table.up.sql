@@ -0,0 +1,22 @@
+-- Uploaded files, orphans are attachments not referenced by any post
+CREATE TABLE IF NOT EXISTS attachments
+(
+    id           bigserial PRIMARY KEY,
+    user_id      bigint,
+    post_id      bigint,
+    storage_key  varchar(255) NOT NULL UNIQUE,
+    content_type varchar(100) NOT NULL,
+    size         bigint       NOT NULL,
+    width        int          NOT NULL,
+    height       int          NOT NULL,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+
+    -- Attachments of purged users become orphans too, so their files are not leaked
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+
+    -- Attachments of purged posts become orphans, their files are deleted by the garbage collector
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_orphans ON attachments (created_at) WHERE post_id IS NULL;
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet is implemented by authenticators able to publish their public keys.
+type KeySet interface {
+	JWKS() (JWKS, error)
+}
+
+// KeySetAuthenticator signs tokens with asymmetric keys (RS256 / EdDSA).
+// The first key signs new tokens, every key is accepted for validation
+// so keys can be rotated without invalidating issued tokens.
+type KeySetAuthenticator struct {
+	keys     []*SigningKey
+	issuer   string
+	audience string
+}
+
+func NewKeySetAuthenticator(keys []*SigningKey, issuer, audience string) (*KeySetAuthenticator, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicated key id %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+
+	return &KeySetAuthenticator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+	}, nil
+}
+
+func (a *KeySetAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	key := a.keys[0]
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+func (a *KeySetAuthenticator) ValidateToken(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key := a.find(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.PrivateKey.Public(), nil
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(a.issuer),
+		jwt.WithAudience(a.audience),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name, jwt.SigningMethodEdDSA.Alg()}),
+	)
+}
+
+// JWKS returns public keys of every active key.
+func (a *KeySetAuthenticator) JWKS() (JWKS, error) {
+	set := JWKS{Keys: make([]JWK, 0, len(a.keys))}
+
+	for _, k := range a.keys {
+		jwk, err := k.JWK()
+		if err != nil {
+			return JWKS{}, err
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+func (a *KeySetAuthenticator) find(kid string) *SigningKey {
+	for _, k := range a.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+
+	return nil
+}
@@ -13,6 +13,7 @@ var testClaims = jwt.MapClaims{
 	"aud": "test-aud",
 	"iss": "test-aud",
 	"sub": int64(1),
+	"jti": "00000000-0000-0000-0000-000000000000",
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
@@ -0,0 +1,202 @@
+// Package diff compares texts line by line.
+package diff
+
+import (
+	"errors"
+	"strings"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// MaxEdits is the largest number of inserted and deleted lines Lines computes,
+// it bounds the time spent comparing unrelated texts.
+const MaxEdits = 5000
+
+// ErrTooManyEdits is returned when the texts differ by more than MaxEdits lines.
+var ErrTooManyEdits = errors.New("texts differ by too many lines")
+
+// Line is a line of the edit script turning a text into another.
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the shortest edit script turning a into b, line by line,
+// computed with the linear space Myers algorithm in O((N+M)D) time.
+// Returns ErrTooManyEdits if the script has more than MaxEdits insertions and deletions.
+func Lines(a, b string) ([]Line, error) {
+	x, y := splitLines(a), splitLines(b)
+
+	d := &differ{lines: make([]Line, 0, max(len(x), len(y)))}
+	if err := d.diff(x, y); err != nil {
+		return nil, err
+	}
+
+	return d.lines, nil
+}
+
+type differ struct {
+	lines []Line
+}
+
+// diff appends the edit script turning x into y, split at the middle snake
+// of both texts until they have nothing in common.
+func (d *differ) diff(x, y []string) error {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	d.append(Equal, x[:prefix])
+	x, y = x[prefix:], y[prefix:]
+
+	suffix := 0
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+	switch {
+	case len(x) == 0:
+		d.append(Insert, y)
+	case len(y) == 0:
+		d.append(Delete, x)
+	default:
+		i, j, ok, err := middleSnake(x, y)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			d.append(Delete, x)
+			d.append(Insert, y)
+			break
+		}
+
+		if err = d.diff(x[:i], y[:j]); err != nil {
+			return err
+		}
+		if err = d.diff(x[i:], y[j:]); err != nil {
+			return err
+		}
+	}
+
+	d.append(Equal, common)
+
+	return nil
+}
+
+func (d *differ) append(op Op, lines []string) {
+	for _, text := range lines {
+		d.lines = append(d.lines, Line{Op: op, Text: text})
+	}
+}
+
+// middleSnake searches the shortest edit script of x and y from both ends at once,
+// returns the point where both searches overlap, which splits the script in two halves.
+// ok is false when x and y have nothing in common.
+func middleSnake(x, y []string) (i, j int, ok bool, err error) {
+	n, m := len(x), len(y)
+
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	size := 2*maxD + 3
+
+	// vf[offset+k] is the furthest index of x reached on diagonal k from the start,
+	// vb[offset+k] the furthest distance from the end of x reached backwards
+	vf, vb := make([]int, size), make([]int, size)
+	for k := range vf {
+		vf[k], vb[k] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta, the paths overlap during the forward search
+	front := delta%2 != 0
+
+	// Diagonals leaving the edit graph are skipped in later steps
+	var fStart, fEnd, bStart, bEnd int
+
+	for d := 0; d < maxD; d++ {
+		// Each step of both searches adds up to two edits
+		if 2*d > MaxEdits {
+			return 0, 0, false, ErrTooManyEdits
+		}
+
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var xi int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				xi = vf[offset+k+1]
+			} else {
+				xi = vf[offset+k-1] + 1
+			}
+
+			yi := xi - k
+			for xi < n && yi < m && x[xi] == y[yi] {
+				xi++
+				yi++
+			}
+			vf[offset+k] = xi
+
+			switch {
+			case xi > n:
+				fEnd += 2
+			case yi > m:
+				fStart += 2
+			case front:
+				bk := offset + delta - k
+				if bk >= 0 && bk < size && vb[bk] != -1 && xi >= n-vb[bk] {
+					return xi, yi, true, nil
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var xi int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				xi = vb[offset+k+1]
+			} else {
+				xi = vb[offset+k-1] + 1
+			}
+
+			yi := xi - k
+			for xi < n && yi < m && x[n-xi-1] == y[m-yi-1] {
+				xi++
+				yi++
+			}
+			vb[offset+k] = xi
+
+			switch {
+			case xi > n:
+				bEnd += 2
+			case yi > m:
+				bStart += 2
+			case !front:
+				fk := offset + delta - k
+				if fk >= 0 && fk < size && vf[fk] != -1 {
+					fx := vf[fk]
+					fy := fx - (fk - offset)
+					if fx >= n-xi {
+						return fx, fy, true, nil
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false, nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,158 @@
+package diff
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "both empty",
+			want: []Line{},
+		},
+		{
+			name: "insert everything",
+			b:    "a\nb",
+			want: []Line{{Insert, "a"}, {Insert, "b"}},
+		},
+		{
+			name: "delete everything",
+			a:    "a\nb",
+			want: []Line{{Delete, "a"}, {Delete, "b"}},
+		},
+		{
+			name: "equal",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name: "replace a line",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc",
+			want: []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+		},
+		{
+			name: "insert in the middle",
+			a:    "a\nc",
+			b:    "a\nb\nc",
+			want: []Line{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}},
+		},
+		{
+			name: "CRLF line endings",
+			a:    "a\r\nb",
+			b:    "a\nb",
+			want: []Line{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name: "nothing in common",
+			a:    "a\nb",
+			b:    "c\nd",
+			want: []Line{{Delete, "a"}, {Delete, "b"}, {Insert, "c"}, {Insert, "d"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lines(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Lines() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLinesShortest checks random texts: the script must turn a into b
+// and have as many equal lines as their longest common subsequence.
+func TestLinesShortest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	randomText := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = strconv.Itoa(rnd.Intn(4))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomText(), randomText()
+
+		got, err := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+		if err != nil {
+			t.Fatalf("Lines(%q, %q) error = %v", a, b, err)
+		}
+
+		var from, to []string
+		equal := 0
+		for _, line := range got {
+			switch line.Op {
+			case Equal:
+				from = append(from, line.Text)
+				to = append(to, line.Text)
+				equal++
+			case Delete:
+				from = append(from, line.Text)
+			case Insert:
+				to = append(to, line.Text)
+			}
+		}
+
+		if !reflect.DeepEqual(from, nilIfEmpty(a)) || !reflect.DeepEqual(to, nilIfEmpty(b)) {
+			t.Fatalf("Lines(%q, %q) = %v does not turn a into b", a, b, got)
+		}
+
+		if want := lcs(a, b); equal != want {
+			t.Fatalf("Lines(%q, %q) keeps %d lines, want %d", a, b, equal, want)
+		}
+	}
+}
+
+func TestLinesTooManyEdits(t *testing.T) {
+	a := strings.Repeat("a\n", MaxEdits)
+	b := strings.Repeat("b\n", MaxEdits)
+
+	if _, err := Lines(a, b); !errors.Is(err, ErrTooManyEdits) {
+		t.Errorf("Lines() error = %v, want %v", err, ErrTooManyEdits)
+	}
+}
+
+func nilIfEmpty(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+
+	return dp[0][0]
+}
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // GetString reads environment variable, returns fallback If the key
@@ -24,7 +25,7 @@ func GetInt(key string, fallback int) int {
 		return fallback
 	}
 
-	intVal, err := strconv.Atoi(key)
+	intVal, err := strconv.Atoi(val)
 	if err != nil {
 		return fallback
 	}
@@ -38,7 +39,7 @@ func GetFloat64(key string, fallback float64) float64 {
 		return fallback
 	}
 
-	floatVal, err := strconv.ParseFloat(key, 64)
+	floatVal, err := strconv.ParseFloat(val, 64)
 	if err != nil {
 		return fallback
 	}
@@ -59,3 +60,21 @@ func GetBool(key string, fallback bool) bool {
 
 	return boolVal
 }
+
+// GetStrings reads comma separated environment variable, returns fallback
+// If the key not exists, otherwise return trimmed non-empty values.
+func GetStrings(key string, fallback []string) []string {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback
+	}
+
+	var values []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
@@ -3,13 +3,13 @@ package internal
 import "embed"
 
 var (
-	MaxRetries   = 3
-	TemplatePath = "user_invitations.tmpl"
+	TemplatePath              = "user_invitations.tmpl"
+	PasswordResetTemplatePath = "password_reset.tmpl"
 )
 
 //go:embed templates
 var FS embed.FS
 
 type Client interface {
-	Send(templateFile string, toEmail []string, data any) error
+	Send(templateFile, locale string, toEmail []string, data any) error
 }
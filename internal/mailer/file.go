@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// FileMailer writes rendered emails as .eml files into a directory,
+// used for local development and tests.
+type FileMailer struct {
+	templates *Templates
+	fromEmail string
+	dir       string
+}
+
+func NewFileMailer(templates *Templates, fromEmail, dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{
+		templates: templates,
+		fromEmail: fromEmail,
+		dir:       dir,
+	}, nil
+}
+
+func (m *FileMailer) Send(templateFile, locale string, toEmail []string, data any) error {
+	msg, err := newMessage(m.templates, m.fromEmail, templateFile, locale, toEmail, data)
+	if err != nil {
+		return err
+	}
+
+	// Time prefix keeps files sorted, random suffix keeps them unique
+	pattern := fmt.Sprintf("%s-*.eml", time.Now().UTC().Format("20060102T150405"))
+
+	f, err := os.CreateTemp(m.dir, pattern)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = msg.mime().WriteTo(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryCapacity is the number of most recent messages kept by MemoryMailer.
+const memoryCapacity = 100
+
+// MemoryMailer keeps rendered emails in memory so they can be inspected
+// through a debug endpoint, used for local development and tests.
+type MemoryMailer struct {
+	templates *Templates
+	fromEmail string
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer(templates *Templates, fromEmail string) *MemoryMailer {
+	return &MemoryMailer{templates: templates, fromEmail: fromEmail}
+}
+
+func (m *MemoryMailer) Send(templateFile, locale string, toEmail []string, data any) error {
+	msg, err := newMessage(m.templates, m.fromEmail, templateFile, locale, toEmail, data)
+	if err != nil {
+		return err
+	}
+	msg.SentAt = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, *msg)
+	if len(m.messages) > memoryCapacity {
+		m.messages = m.messages[len(m.messages)-memoryCapacity:]
+	}
+
+	return nil
+}
+
+// Messages returns sent messages, most recent last.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+
+	return messages
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"gopkg.in/gomail.v2"
+	"time"
+)
+
+// Message is a rendered email.
+type Message struct {
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+	PlainBody string    `json:"plain_body"`
+	HTMLBody  string    `json:"html_body"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// newMessage renders subject, plainBody and htmlBody blocks of the template file in locale.
+func newMessage(templates *Templates, fromEmail, templateFile, locale string, toEmail []string, data any) (*Message, error) {
+	tmpl, err := templates.Lookup(templateFile, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		From:      fromEmail,
+		To:        toEmail,
+		Subject:   subject.String(),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}, nil
+}
+
+// mime builds the multipart MIME message.
+func (m *Message) mime() *gomail.Message {
+	mail := gomail.NewMessage()
+	mail.SetHeader("From", m.From)
+	mail.SetHeader("To", m.To...)
+	mail.SetHeader("Subject", m.Subject)
+	mail.SetBody("text/plain", m.PlainBody)
+	mail.AddAlternative("text/html", m.HTMLBody)
+
+	return mail
+}
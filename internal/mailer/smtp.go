@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gopkg.in/gomail.v2"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// sendTimeout bounds connecting to the SMTP server and delivering a message.
+const sendTimeout = 30 * time.Second
+
+// ErrStartTLSNotSupported is returned when the SMTP server does not offer STARTTLS,
+// messages are never sent in plain text.
+var ErrStartTLSNotSupported = errors.New("smtp server does not support STARTTLS")
+
+// SMTPMailer sends emails through any SMTP server, using implicit TLS
+// or upgrading the connection with STARTTLS.
+type SMTPMailer struct {
+	templates   *Templates
+	fromEmail   string
+	host        string
+	port        int
+	username    string
+	password    string
+	implicitTLS bool
+}
+
+func NewSMTPMailer(templates *Templates, fromEmail, host, username, password string, port int, implicitTLS bool) *SMTPMailer {
+	return &SMTPMailer{
+		templates:   templates,
+		fromEmail:   fromEmail,
+		host:        host,
+		port:        port,
+		username:    username,
+		password:    password,
+		implicitTLS: implicitTLS,
+	}
+}
+
+func (m *SMTPMailer) Send(templateFile, locale string, toEmail []string, data any) error {
+	msg, err := newMessage(m.templates, m.fromEmail, templateFile, locale, toEmail, data)
+	if err != nil {
+		return err
+	}
+
+	// Retries are handled by the email outbox
+	if err = gomail.Send(gomail.SendFunc(m.send), msg.mime()); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+// send delivers a message over a new encrypted connection.
+// Unlike gomail's dialer, it fails when the server does not offer STARTTLS.
+func (m *SMTPMailer) send(from string, to []string, msg io.WriterTo) error {
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	tlsConfig := &tls.Config{ServerName: m.host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if m.implicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = conn.SetDeadline(time.Now().Add(sendTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !m.implicitTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return ErrStartTLSNotSupported
+		}
+
+		if err = c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if m.username != "" {
+		if err = c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
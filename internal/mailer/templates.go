@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is used when an email has no variant in the requested locale.
+const DefaultLocale = "en"
+
+// requiredBlocks are the blocks every email template must define.
+var requiredBlocks = []string{"subject", "plainBody", "htmlBody"}
+
+// Templates is a registry of email templates parsed once at startup.
+//
+// "<name>.tmpl" is the default locale variant of the template,
+// "<name>.<locale>.tmpl" (e.g. "user_invitations.vi.tmpl") is its localised variant.
+type Templates struct {
+	// templates maps template name, then locale, to the parsed template
+	templates map[string]map[string]*template.Template
+	locales   map[string]bool
+}
+
+// LoadTemplates parses every .tmpl file of dir in fsys and checks that
+// they define the required blocks.
+func LoadTemplates(fsys fs.FS, dir string) (*Templates, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{
+		templates: make(map[string]map[string]*template.Template),
+		locales:   map[string]bool{DefaultLocale: true},
+	}
+
+	for _, file := range files {
+		name, locale := splitTemplateFile(path.Base(file))
+
+		tmpl, err := template.ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, block := range requiredBlocks {
+			if tmpl.Lookup(block) == nil {
+				return nil, fmt.Errorf("template %s: missing %q block", file, block)
+			}
+		}
+
+		if t.templates[name] == nil {
+			t.templates[name] = make(map[string]*template.Template)
+		}
+		t.templates[name][locale] = tmpl
+		t.locales[locale] = true
+	}
+
+	for name, variants := range t.templates {
+		if variants[DefaultLocale] == nil {
+			return nil, fmt.Errorf("template %s: missing default variant %s.tmpl", name, name)
+		}
+	}
+
+	return t, nil
+}
+
+// Lookup returns the variant of the template in locale, falling back on the
+// base language (e.g. "vi" for "vi-VN") and then on the default locale.
+func (t *Templates) Lookup(templateFile, locale string) (*template.Template, error) {
+	name, _ := splitTemplateFile(templateFile)
+
+	variants, ok := t.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", templateFile)
+	}
+
+	locale = strings.ToLower(locale)
+	if tmpl, ok := variants[locale]; ok {
+		return tmpl, nil
+	}
+
+	if base, _, found := strings.Cut(locale, "-"); found {
+		if tmpl, ok := variants[base]; ok {
+			return tmpl, nil
+		}
+	}
+
+	return variants[DefaultLocale], nil
+}
+
+// Match returns the first of the preferred locales having email templates,
+// or DefaultLocale if none of them has.
+func (t *Templates) Match(preferred ...string) string {
+	for _, locale := range preferred {
+		locale = strings.ToLower(locale)
+		if t.locales[locale] {
+			return locale
+		}
+
+		if base, _, found := strings.Cut(locale, "-"); found && t.locales[base] {
+			return base
+		}
+	}
+
+	return DefaultLocale
+}
+
+// Locales returns the supported locales, sorted.
+func (t *Templates) Locales() []string {
+	locales := make([]string, 0, len(t.locales))
+	for locale := range t.locales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+// splitTemplateFile splits "user_invitations.vi.tmpl" into "user_invitations" and "vi",
+// files without locale belong to DefaultLocale.
+func splitTemplateFile(file string) (name, locale string) {
+	name = strings.TrimSuffix(file, ".tmpl")
+
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i], strings.ToLower(name[i+1:])
+	}
+
+	return name, DefaultLocale
+}
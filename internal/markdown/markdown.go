@@ -0,0 +1,67 @@
+// Package markdown renders user written Markdown into sanitised HTML.
+package markdown
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// md renders GitHub flavoured Markdown, raw HTML is omitted
+	md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// policy is the allow-list of elements and attributes kept in rendered HTML
+	policy = newPolicy()
+
+	// textPolicy strips every tag, keeping the text
+	textPolicy = bluemonday.StrictPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	// Task list items of GFM
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return p
+}
+
+// Render converts Markdown to HTML, sanitised against XSS.
+func Render(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
+
+// Excerpt returns the plain text of rendered HTML, cut on a word boundary
+// to at most maxRunes runes, followed by an ellipsis if it was cut.
+func Excerpt(renderedHTML string, maxRunes int) string {
+	text := html.UnescapeString(textPolicy.Sanitize(renderedHTML))
+	text = strings.Join(strings.Fields(text), " ")
+
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+
+	runes := []rune(text)
+
+	// The cut ends a word if the next rune is a space, otherwise the cut word is dropped
+	cut := string(runes[:maxRunes])
+	if runes[maxRunes] != ' ' {
+		if i := strings.LastIndexByte(cut, ' '); i > 0 {
+			cut = cut[:i]
+		}
+	}
+
+	return cut + "…"
+}
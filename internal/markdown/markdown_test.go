@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "emphasis and links",
+			src:      "*hi* [link](https://example.com)",
+			contains: []string{"<em>hi</em>", `<a href="https://example.com" rel="nofollow">link</a>`},
+		},
+		{
+			name:     "GFM table and strikethrough",
+			src:      "| a |\n|---|\n| b |\n\n~~old~~",
+			contains: []string{"<table>", "<td>b</td>", "<del>old</del>"},
+		},
+		{
+			name:     "task list",
+			src:      "- [x] done",
+			contains: []string{`<input checked="" disabled="" type="checkbox">`},
+		},
+		{
+			name:     "raw HTML script",
+			src:      "<script>alert(1)</script>",
+			excludes: []string{"<script", "alert(1)</script>"},
+		},
+		{
+			name:     "javascript link",
+			src:      "[x](javascript:alert(1))",
+			excludes: []string{"javascript:"},
+		},
+		{
+			name:     "event handler attribute",
+			src:      `<img src="x" onerror="alert(1)">`,
+			excludes: []string{"onerror"},
+		},
+		{
+			name:     "image with data URL",
+			src:      "![x](data:text/html;base64,PHNjcmlwdD4=)",
+			excludes: []string{"data:text/html"},
+		},
+		{
+			name:     "non checkbox input",
+			src:      `<input type="text" value="x">`,
+			excludes: []string{"<input"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.src)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("Render() = %q, want it to contain %q", got, s)
+				}
+			}
+
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("Render() = %q, want it not to contain %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		maxRunes int
+		want     string
+	}{
+		{"short text", "<p>Hello <em>world</em></p>", 20, "Hello world"},
+		{"exact length", "<p>Hello world</p>", 11, "Hello world"},
+		{"cut on a word boundary", "<p>Hello wonderful world</p>", 12, "Hello…"},
+		{"cut after a whole word", "<p>Hello world again</p>", 11, "Hello world…"},
+		{"single long word", "<p>Supercalifragilistic</p>", 5, "Super…"},
+		{"whitespace collapsed", "<p>a</p>\n\n<p>b   c</p>", 10, "a b c"},
+		{"entities unescaped", "<p>Tom &amp; Jerry &lt;3</p>", 20, "Tom & Jerry <3"},
+		{"multibyte runes", "<p>Xin chào thế giới</p>", 8, "Xin chào…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Excerpt(tt.html, tt.maxRunes); got != tt.want {
+				t.Errorf("Excerpt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
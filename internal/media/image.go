@@ -0,0 +1,307 @@
+// Package media validates uploaded images and strips their metadata.
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, expected a JPEG, PNG or GIF image")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+// extensions of the supported content types
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is an uploaded image stripped of its metadata.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// ParseImage sniffs the content type of data, whatever the client claims,
+// and strips EXIF, XMP, comments and other metadata which may reveal
+// where and when a photo was taken.
+func ParseImage(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		data, err = stripJPEG(data)
+	case "image/png":
+		data, err = stripPNG(data)
+	case "image/gif":
+		data, err = stripGIF(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the stripped file is still an image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	return &Image{
+		Data:        data,
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}, nil
+}
+
+// iccProfile starts the APP2 segments holding an ICC color profile
+var iccProfile = []byte("ICC_PROFILE\x00")
+
+// stripJPEG removes EXIF, XMP, IPTC and comment segments, keeping the JFIF header,
+// ICC color profile (APP2) and Adobe color transform (APP14).
+// Anything after the end of image marker is dropped.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrInvalidImage
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	for pos := 2; ; {
+		if pos+2 > len(data) || data[pos] != 0xFF {
+			return nil, ErrInvalidImage
+		}
+
+		marker := data[pos+1]
+		if marker == 0xD9 {
+			return append(out, data[pos:pos+2]...), nil
+		}
+
+		// The segment length includes its own two bytes
+		if pos+4 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		// Compressed data follows the start of scan header up to the next marker
+		if marker == 0xDA {
+			scanEnd, err := skipScan(data, end)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, data[pos:scanEnd]...)
+			pos = scanEnd
+			continue
+		}
+
+		if keepJPEGSegment(marker, data[pos+4:end]) {
+			out = append(out, data[pos:end]...)
+		}
+
+		pos = end
+	}
+}
+
+// keepJPEGSegment reports whether a segment is kept, given its marker and payload.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xFE:
+		return false
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, iccProfile)
+	case marker >= 0xE1 && marker <= 0xEF:
+		return marker == 0xEE
+	default:
+		return true
+	}
+}
+
+// skipScan returns the position of the marker ending the entropy coded data starting at pos.
+// Inside it, 0xFF is followed by a stuffed zero, a restart marker or another 0xFF fill byte.
+func skipScan(data []byte, pos int) (int, error) {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+
+		next := data[pos+1]
+		if next == 0xFF {
+			continue
+		}
+		if next == 0x00 || (next >= 0xD0 && next <= 0xD7) {
+			pos++
+			continue
+		}
+
+		return pos, nil
+	}
+
+	return 0, ErrInvalidImage
+}
+
+// pngMetadataChunks are dropped from PNG images
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+// stripPNG removes EXIF, text and timestamp chunks.
+func stripPNG(data []byte) ([]byte, error) {
+	const signatureLength = 8
+
+	if len(data) < signatureLength {
+		return nil, ErrInvalidImage
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLength]...)
+
+	for pos := signatureLength; ; {
+		// Chunk length, type, data and CRC
+		if pos+12 > len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		typ := string(data[pos+4 : pos+8])
+		if !pngMetadataChunks[typ] {
+			out = append(out, data[pos:end]...)
+		}
+
+		if typ == "IEND" {
+			return out, nil
+		}
+
+		pos = end
+	}
+}
+
+// gifKeptApplications are the application extensions kept in GIF images, for looping animations
+var gifKeptApplications = map[string]bool{
+	"NETSCAPE2.0": true,
+	"ANIMEXTS1.0": true,
+}
+
+// stripGIF removes comment extensions and application extensions such as XMP,
+// keeping images, their graphic controls and the loop count of animations.
+func stripGIF(data []byte) ([]byte, error) {
+	// Header and logical screen descriptor
+	const headerLength = 13
+
+	if len(data) < headerLength {
+		return nil, ErrInvalidImage
+	}
+
+	pos := headerLength
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	if pos > len(data) {
+		return nil, ErrInvalidImage
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:pos]...)
+
+	for {
+		if pos >= len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		start := pos
+		keep := true
+
+		switch data[pos] {
+		case 0x3B: // Trailer, anything after it is dropped
+			return append(out, 0x3B), nil
+
+		case 0x21: // Extension
+			if pos+2 > len(data) {
+				return nil, ErrInvalidImage
+			}
+
+			label := data[pos+1]
+			pos += 2
+
+			switch label {
+			case 0xFE:
+				keep = false
+			case 0xFF:
+				// The application identifier is the first sub-block
+				if pos >= len(data) || pos+1+int(data[pos]) > len(data) {
+					return nil, ErrInvalidImage
+				}
+				keep = gifKeptApplications[string(data[pos+1:pos+1+int(data[pos])])]
+			}
+
+		case 0x2C: // Image descriptor, then its local color table and LZW minimum code size
+			if pos+10 > len(data) {
+				return nil, ErrInvalidImage
+			}
+
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos++
+
+		default:
+			return nil, ErrInvalidImage
+		}
+
+		// Data sub-blocks, up to the block terminator
+		for {
+			if pos >= len(data) {
+				return nil, ErrInvalidImage
+			}
+
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+
+		if pos > len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		if keep {
+			out = append(out, data[start:pos]...)
+		}
+	}
+}
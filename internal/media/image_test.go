@@ -0,0 +1,232 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage() image.Image {
+	img := image.NewPaletted(image.Rect(0, 0, 3, 2), color.Palette{color.Black, color.White})
+	img.SetColorIndex(1, 1, 1)
+	return img
+}
+
+func encodeJPEG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithMetadata inserts an EXIF segment and a comment after the start of image marker.
+func jpegWithMetadata(t *testing.T) []byte {
+	data := encodeJPEG(t)
+
+	exif := append([]byte{0xFF, 0xE1, 0x00, 0x12}, []byte("Exif\x00\x00GPS-SECRET")...)
+	comment := append([]byte{0xFF, 0xFE, 0x00, 0x0C}, []byte("COM-SECRET")...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exif...)
+	out = append(out, comment...)
+	return append(out, data[2:]...)
+}
+
+// pngWithMetadata inserts a text chunk after the IHDR chunk.
+func pngWithMetadata(t *testing.T) []byte {
+	data := encodePNG(t)
+
+	text := []byte("Comment\x00TXT-SECRET")
+	chunk := []byte{0, 0, 0, byte(len(text))}
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = append(chunk, 0, 0, 0, 0) // CRC, not checked by DecodeConfig
+
+	// Signature, then IHDR length, type, 13 bytes of data and CRC
+	ihdrEnd := 8 + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+// gifWithMetadata inserts a comment and an XMP application extension before the first image.
+func gifWithMetadata(t *testing.T) []byte {
+	data := encodeGIF(t)
+
+	comment := append([]byte{0x21, 0xFE, 10}, "GIF-SECRET"...)
+	comment = append(comment, 0)
+
+	xmp := append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...)
+	xmp = append(xmp, 10)
+	xmp = append(xmp, "XMP-SECRET"...)
+	xmp = append(xmp, 0)
+
+	// Header, logical screen descriptor and global color table of 2 colors
+	headerEnd := 13 + 3*2
+	out := append([]byte{}, data[:headerEnd]...)
+	out = append(out, comment...)
+	out = append(out, xmp...)
+	return append(out, data[headerEnd:]...)
+}
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		ext         string
+	}{
+		{"jpeg", jpegWithMetadata(t), "image/jpeg", ".jpg"},
+		{"png", pngWithMetadata(t), "image/png", ".png"},
+		{"gif", gifWithMetadata(t), "image/gif", ".gif"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ParseImage(tt.data)
+			if err != nil {
+				t.Fatalf("ParseImage() error = %v", err)
+			}
+
+			if img.ContentType != tt.contentType || img.Ext != tt.ext {
+				t.Errorf("ParseImage() = %s %s, want %s %s", img.ContentType, img.Ext, tt.contentType, tt.ext)
+			}
+
+			if img.Width != 3 || img.Height != 2 {
+				t.Errorf("ParseImage() size = %dx%d, want 3x2", img.Width, img.Height)
+			}
+
+			if bytes.Contains(img.Data, []byte("SECRET")) {
+				t.Errorf("ParseImage() kept metadata")
+			}
+
+			if _, _, err = image.Decode(bytes.NewReader(img.Data)); err != nil {
+				t.Errorf("stripped image does not decode: %v", err)
+			}
+		})
+	}
+}
+
+// jpegSegment returns a JPEG segment with the given marker and payload.
+func jpegSegment(marker byte, payload string) []byte {
+	length := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+}
+
+func TestParseImageJPEGSegments(t *testing.T) {
+	data := encodeJPEG(t)
+
+	icc := jpegSegment(0xE2, "ICC_PROFILE\x00\x01\x01ICC-KEPT")
+	flashpix := jpegSegment(0xE2, "FPXR\x00APP2-SECRET")
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, icc...)
+	out = append(out, flashpix...)
+	out = append(out, data[2:]...)
+	out = append(out, "TRAILER-SECRET"...)
+
+	img, err := ParseImage(out)
+	if err != nil {
+		t.Fatalf("ParseImage() error = %v", err)
+	}
+
+	if !bytes.Contains(img.Data, icc) {
+		t.Errorf("ParseImage() dropped the ICC profile")
+	}
+
+	if bytes.Contains(img.Data, []byte("SECRET")) {
+		t.Errorf("ParseImage() kept metadata")
+	}
+
+	if !bytes.HasSuffix(img.Data, []byte{0xFF, 0xD9}) {
+		t.Errorf("ParseImage() does not end at the end of image marker")
+	}
+
+	if _, _, err = image.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Errorf("stripped image does not decode: %v", err)
+	}
+}
+
+func TestParseImageKeepsAnimationLoop(t *testing.T) {
+	var buf bytes.Buffer
+	anim := &gif.GIF{
+		Image: []*image.Paletted{testImage().(*image.Paletted), testImage().(*image.Paletted)},
+		Delay: []int{10, 10},
+	}
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := ParseImage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseImage() error = %v", err)
+	}
+
+	if !bytes.Contains(img.Data, []byte("NETSCAPE2.0")) {
+		t.Errorf("ParseImage() dropped the loop extension")
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("stripped animation does not decode: %v", err)
+	}
+
+	if len(decoded.Image) != 2 {
+		t.Errorf("stripped animation has %d frames, want 2", len(decoded.Image))
+	}
+}
+
+func TestParseImageInvalid(t *testing.T) {
+	jpegData := encodeJPEG(t)
+	pngData := encodePNG(t)
+	gifData := encodeGIF(t)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrUnsupportedType},
+		{"text", []byte("hello, world"), ErrUnsupportedType},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), ErrUnsupportedType},
+		{"truncated jpeg", jpegData[:len(jpegData)/3], ErrInvalidImage},
+		{"jpeg segment past the end", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, make([]byte, 16)...), ErrInvalidImage},
+		{"jpeg segment too short", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00}, make([]byte, 16)...), ErrInvalidImage},
+		{"jpeg without end of image", jpegData[:len(jpegData)-2], ErrInvalidImage},
+		{"truncated png", pngData[:len(pngData)-6], ErrInvalidImage},
+		{"png chunk past the end", append(append([]byte{}, pngData[:8]...), 0x7F, 0xFF, 0xFF, 0xFF, 'I', 'H', 'D', 'R'), ErrInvalidImage},
+		{"truncated gif", gifData[:len(gifData)-4], ErrInvalidImage},
+		{"gif without trailer", gifData[:len(gifData)-1], ErrInvalidImage},
+		{"gif unknown block", append(append([]byte{}, gifData[:19]...), 0x42), ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseImage(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("ParseImage() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
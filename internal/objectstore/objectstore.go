@@ -0,0 +1,17 @@
+// Package objectstore stores uploaded files, on the local filesystem
+// or in an S3-compatible bucket.
+package objectstore
+
+import (
+	"context"
+	"io"
+)
+
+type Store interface {
+	// Put stores size bytes of r under key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete removes the object stored under key, deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the object stored under key.
+	URL(key string) string
+}
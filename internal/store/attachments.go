@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+// ErrUnknownAttachment is returned when a post references attachments
+// which do not exist, belong to another user or to another post.
+var ErrUnknownAttachment = errors.New("unknown attachment")
+
+type IAttachments interface {
+	Create(ctx context.Context, attachment *Attachment) error
+	DeleteOrphans(ctx context.Context, createdBefore time.Time, limit int) ([]string, error)
+}
+
+// Attachment is a file uploaded by a user, attached to at most one post.
+// URL is not stored, it is built from Key by the object store of the files.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	PostID      *int64    `json:"post_id"`
+	Key         string    `json:"-"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type AttachmentStorage struct {
+	db *sql.DB
+}
+
+// Create saves an uploaded file, not attached to any post yet.
+func (s *AttachmentStorage) Create(ctx context.Context, a *Attachment) error {
+	query := `
+		INSERT INTO attachments (user_id, storage_key, content_type, size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		a.UserID,
+		a.Key,
+		a.ContentType,
+		a.Size,
+		a.Width,
+		a.Height,
+	).Scan(&a.ID, &a.CreatedAt)
+}
+
+// DeleteOrphans deletes up to limit attachments created before createdBefore
+// and not attached to any post, returns storage keys of their files.
+// Deleted rows can no longer be attached, so their files are safe to delete.
+func (s *AttachmentStorage) DeleteOrphans(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+	query := `
+		DELETE FROM attachments
+		WHERE id IN (
+			SELECT id FROM attachments
+			WHERE post_id IS NULL AND created_at < $1
+			ORDER BY created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING storage_key
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// attach replaces the attachments of the post by post.AttachmentIDs, which must belong to its author,
+// and loads them into post.Attachments.
+// Returns ErrUnknownAttachment if any of them does not exist, belongs to another user
+// or is attached to another post.
+func attach(ctx context.Context, tx *sql.Tx, post *Post) error {
+	detachQuery := `UPDATE attachments SET post_id = NULL WHERE post_id = $1 AND NOT id = ANY($2)`
+	attachQuery := `
+		UPDATE attachments SET post_id = $1
+		WHERE id = ANY($2) AND user_id = $3 AND (post_id IS NULL OR post_id = $1)
+	`
+	selectQuery := `SELECT ` + attachmentsColumn + ` FROM posts p WHERE p.id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	ids := pq.Array(post.AttachmentIDs)
+
+	if _, err := tx.ExecContext(ctx, detachQuery, post.ID, ids); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, attachQuery, post.ID, ids, post.UserID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != int64(len(post.AttachmentIDs)) {
+		return ErrUnknownAttachment
+	}
+
+	var attachments []byte
+	if err = tx.QueryRowContext(ctx, selectQuery, post.ID).Scan(&attachments); err != nil {
+		return err
+	}
+
+	post.Attachments, err = unmarshalAttachments(attachments)
+	return err
+}
+
+// unmarshalAttachments decodes attachments selected by attachmentsColumn.
+func unmarshalAttachments(data []byte) ([]Attachment, error) {
+	// Key is hidden from responses, it is decoded from its own field
+	var rows []struct {
+		Attachment
+		Key string `json:"storage_key"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+
+	attachments := make([]Attachment, len(rows))
+	for i, row := range rows {
+		attachments[i] = row.Attachment
+		attachments[i].Key = row.Key
+	}
+
+	return attachments, nil
+}
+
+// attachmentsColumn selects attachments of posts p as a JSON array.
+const attachmentsColumn = `(
+	SELECT COALESCE(json_agg(json_build_object(
+		'id', a.id, 'user_id', a.user_id, 'post_id', a.post_id, 'storage_key', a.storage_key, 'content_type', a.content_type,
+		'size', a.size, 'width', a.width, 'height', a.height, 'created_at', a.created_at
+	) ORDER BY a.id), '[]')
+	FROM attachments a WHERE a.post_id = p.id
+)`
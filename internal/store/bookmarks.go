@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+type IBookmarks interface {
+	Add(ctx context.Context, userID, postID int64) error
+	Remove(ctx context.Context, userID, postID int64) error
+	GetByUserID(ctx context.Context, userID int64, q PaginatedBookmarkQuery) ([]Bookmark, Page, error)
+}
+
+// Bookmark is a post saved by a user.
+type Bookmark struct {
+	PostWithMetadata
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+type BookmarkStorage struct {
+	db *sql.DB
+}
+
+// Add bookmarks the post, bookmarking it twice is not an error.
+// Returns ErrNotFound if the post does not exist.
+func (s *BookmarkStorage) Add(ctx context.Context, userID, postID int64) error {
+	query := `INSERT INTO bookmarks (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Remove returns ErrNotFound if the post was not bookmarked.
+func (s *BookmarkStorage) Remove(ctx context.Context, userID, postID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByUserID lists posts bookmarked by the user, most recently bookmarked first,
+// with the same metadata as the feed. Posts the user can no longer see are skipped.
+func (s *BookmarkStorage) GetByUserID(ctx context.Context, userID int64, q PaginatedBookmarkQuery) ([]Bookmark, Page, error) {
+	order, cmp := keysetOrder("desc", q.Cursor)
+
+	query := `
+		SELECT ` + postMetadataColumns("$1") + `, b.created_at
+		FROM bookmarks b
+		JOIN posts p ON p.id = b.post_id
+		LEFT JOIN users u ON p.user_id = u.id
+		WHERE b.user_id = $1 AND p.status = 'published' AND p.deleted_at IS NULL AND u.deleted_at IS NULL
+		  AND ` + visibleTo("p.user_id", "$1") + `
+		  AND ($2::timestamptz IS NULL OR (b.created_at, b.post_id) ` + cmp + ` ($2, $3))
+		ORDER BY b.created_at ` + order + `, b.post_id ` + order + `
+		LIMIT $4
+	`
+
+	after, afterID := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// Fetch one more post to know whether there is another page
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	var bookmarks []Bookmark
+	for rows.Next() {
+		var b Bookmark
+		if err = scanPostWithMetadata(rows, &b.PostWithMetadata, &b.BookmarkedAt); err != nil {
+			return nil, Page{}, err
+		}
+
+		bookmarks = append(bookmarks, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	bookmarks, page := paginate(bookmarks, q.Limit, q.Cursor, func(b Bookmark) Cursor {
+		return Cursor{CreatedAt: b.BookmarkedAt, ID: b.ID}
+	})
+
+	return bookmarks, page, nil
+}
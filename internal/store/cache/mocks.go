@@ -4,11 +4,13 @@ import (
 	"context"
 	"github.com/minhnghia2k3/GOssage/internal/store"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:    &MockUserStore{},
+		Sessions: &MockSessionStore{},
 	}
 }
 
@@ -25,3 +27,22 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
+
+func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockSessionStore struct {
+	mock.Mock
+}
+
+func (m *MockSessionStore) Revoke(ctx context.Context, sessionID string, exp time.Duration) error {
+	args := m.Called(sessionID, exp)
+	return args.Error(0)
+}
+
+func (m *MockSessionStore) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	args := m.Called(sessionID)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type ISessions interface {
+	Revoke(ctx context.Context, sessionID string, exp time.Duration) error
+	IsRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+type SessionStorage struct {
+	rdb *redis.Client
+}
+
+// Revoke adds session to the revocation set, the key only needs to live
+// as long as access tokens issued for the session.
+func (s *SessionStorage) Revoke(ctx context.Context, sessionID string, exp time.Duration) error {
+	key := fmt.Sprintf("revoked-session-%s", sessionID)
+
+	return s.rdb.Set(ctx, key, 1, exp).Err()
+}
+
+func (s *SessionStorage) IsRevoked(ctx context.Context, sessionID string) (bool, error) {
+	key := fmt.Sprintf("revoked-session-%s", sessionID)
+
+	n, err := s.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
@@ -3,11 +3,13 @@ package cache
 import "github.com/redis/go-redis/v9"
 
 type Storage struct {
-	Users IUsers
+	Users    IUsers
+	Sessions ISessions
 }
 
 func NewRedisStorage(rdb *redis.Client) *Storage {
 	return &Storage{
-		Users: &UserStorage{rdb: rdb},
+		Users:    &UserStorage{rdb: rdb},
+		Sessions: &SessionStorage{rdb: rdb},
 	}
 }
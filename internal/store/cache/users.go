@@ -13,6 +13,7 @@ import (
 type IUsers interface {
 	Get(ctx context.Context, userID int64) (*store.User, error)
 	Set(ctx context.Context, user *store.User) error
+	Delete(ctx context.Context, userID int64) error
 }
 
 type UserStorage struct {
@@ -56,3 +57,10 @@ func (s *UserStorage) Set(ctx context.Context, user *store.User) error {
 
 	return s.rdb.Set(ctx, key, v, UserExpTime).Err()
 }
+
+// Delete evicts the user, so the next Get misses after the user has changed.
+func (s *UserStorage) Delete(ctx context.Context, userID int64) error {
+	key := fmt.Sprintf("user-%d", userID)
+
+	return s.rdb.Del(ctx, key).Err()
+}
@@ -3,20 +3,32 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 )
 
 type IComments interface {
+	GetByID(context.Context, int64) (*Comment, error)
 	Create(context.Context, *Comment) error
-	GetByPostID(context.Context, int64) ([]Comment, error)
+	Update(context.Context, *Comment) error
+	Delete(context.Context, int64) error
+	GetByPostID(context.Context, int64, PaginatedCommentQuery) ([]Comment, Page, error)
 }
 
+// Comment is a comment of a post, or a reply of the ParentID comment.
+// Depth is 0 for top level comments.
 type Comment struct {
-	ID        int64  `json:"id"`
-	UserID    int64  `json:"user_id"`
-	PostID    int64  `json:"post_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	PostID     int64  `json:"post_id"`
+	ParentID   *int64 `json:"parent_id"`
+	Depth      int    `json:"depth"`
+	Content    string `json:"content"`
+	ReplyCount int    `json:"reply_count"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	Version    int    `json:"version"`
+	User       struct {
 		ID       int64  `json:"id"`
 		Username string `json:"name"`
 	} `json:"user"`
@@ -28,62 +40,213 @@ type CommentStorage struct {
 
 func (s *CommentStorage) Create(ctx context.Context, c *Comment) error {
 	query := `
-	INSERT INTO comments (user_id, post_id, content)
-	VALUES ($1, $2, $3)
-	RETURNING id, created_at
+	INSERT INTO comments (user_id, post_id, parent_id, depth, content)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, updated_at, version
 `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
+	var createdAt time.Time
 	err := s.db.QueryRowContext(
 		ctx,
 		query,
 		c.UserID,
 		c.PostID,
+		c.ParentID,
+		c.Depth,
 		c.Content,
-	).Scan(&c.ID, &c.CreatedAt)
+	).Scan(&c.ID, &createdAt, &c.UpdatedAt, &c.Version)
 
 	if err != nil {
 		return err
 	}
 
+	c.CreatedAt = createdAt.Format(time.RFC3339Nano)
+
 	return nil
 }
 
-func (s *CommentStorage) GetByPostID(ctx context.Context, id int64) ([]Comment, error) {
+// GetByID gets a comment by given ID with its author.
+// Comments of deleted posts and users are skipped.
+// If the query select no rows, the function will return ErrNotFound.
+func (s *CommentStorage) GetByID(ctx context.Context, id int64) (*Comment, error) {
 	query := `
-	SELECT c.id, user_id, post_id, content, users.username, users.id, c.created_at
+	SELECT c.id, c.user_id, c.post_id, c.parent_id, c.depth, c.content, users.username, users.id,
+	       c.created_at, c.updated_at, c.version,
+	       (SELECT COUNT(*) FROM comments r
+	        JOIN users ru ON r.user_id = ru.id
+	        WHERE r.parent_id = c.id AND ru.deleted_at IS NULL) AS reply_count
 	FROM comments c
 	JOIN users ON c.user_id = users.id
-	WHERE post_id = $1
-	ORDER BY c.created_at DESC;
+	JOIN posts ON c.post_id = posts.id
+	WHERE c.id = $1 AND posts.deleted_at IS NULL AND users.deleted_at IS NULL
+	  AND ` + authorNotDeleted("posts.user_id") + `
 `
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	var comments []Comment
-	rows, err := s.db.QueryContext(ctx, query, id)
+	var (
+		c         Comment
+		createdAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&c.ID,
+		&c.UserID,
+		&c.PostID,
+		&c.ParentID,
+		&c.Depth,
+		&c.Content,
+		&c.User.Username,
+		&c.User.ID,
+		&createdAt,
+		&c.UpdatedAt,
+		&c.Version,
+		&c.ReplyCount,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	c.CreatedAt = createdAt.Format(time.RFC3339Nano)
+
+	return &c, nil
+}
+
+// Update updates content of a comment at its current version, scan return data into Comment instance
+// or return ErrNotFound if the comment has been changed or deleted in the meantime.
+func (s *CommentStorage) Update(ctx context.Context, c *Comment) error {
+	query := `
+	UPDATE comments
+	SET content = $1, updated_at = now(), version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING updated_at, version
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		c.Content,
+		c.ID,
+		c.Version,
+	).Scan(&c.UpdatedAt, &c.Version)
+
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete deletes a comment by given ID.
+// If there is no such comment, the function will return ErrNotFound.
+func (s *CommentStorage) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM comments WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByPostID gets a page of top level comments of a post, or of replies of q.ParentID,
+// oldest first with their reply counts. Comments of deleted posts and users are skipped.
+func (s *CommentStorage) GetByPostID(ctx context.Context, postID int64, q PaginatedCommentQuery) ([]Comment, Page, error) {
+	order, cmp := keysetOrder("asc", q.Cursor)
+
+	query := `
+	SELECT c.id, c.user_id, c.post_id, c.parent_id, c.depth, c.content, users.username, users.id,
+	       c.created_at, c.updated_at, c.version,
+	       (SELECT COUNT(*) FROM comments r
+	        JOIN users ru ON r.user_id = ru.id
+	        WHERE r.parent_id = c.id AND ru.deleted_at IS NULL) AS reply_count
+	FROM comments c
+	JOIN users ON c.user_id = users.id
+	JOIN posts ON c.post_id = posts.id
+	WHERE c.post_id = $1 AND posts.deleted_at IS NULL AND users.deleted_at IS NULL
+	  AND (($2::bigint IS NULL AND c.parent_id IS NULL) OR c.parent_id = $2)
+	  AND ($3::timestamptz IS NULL OR (c.created_at, c.id) ` + cmp + ` ($3, $4))
+	ORDER BY c.created_at ` + order + `, c.id ` + order + `
+	LIMIT $5
+`
+
+	after, afterID := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// Fetch one more comment to know whether there is another page
+	rows, err := s.db.QueryContext(ctx, query, postID, q.ParentID, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, Page{}, err
 	}
 	defer rows.Close()
 
+	var comments []Comment
 	for rows.Next() {
-		var c Comment
+		var (
+			c         Comment
+			createdAt time.Time
+		)
 		if err = rows.Scan(
 			&c.ID,
 			&c.UserID,
 			&c.PostID,
+			&c.ParentID,
+			&c.Depth,
 			&c.Content,
 			&c.User.Username,
 			&c.User.ID,
-			&c.CreatedAt,
+			&createdAt,
+			&c.UpdatedAt,
+			&c.Version,
+			&c.ReplyCount,
 		); err != nil {
-			return nil, err
+			return nil, Page{}, err
 		}
 
+		c.CreatedAt = createdAt.Format(time.RFC3339Nano)
 		comments = append(comments, c)
 	}
-	return comments, nil
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	comments, page := paginate(comments, q.Limit, q.Cursor, func(c Comment) Cursor {
+		createdAt, _ := time.Parse(time.RFC3339Nano, c.CreatedAt)
+		return Cursor{CreatedAt: createdAt, ID: c.ID}
+	})
+
+	return comments, page, nil
 }
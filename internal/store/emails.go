@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusDead    = "dead"
+)
+
+type IEmails interface {
+	Enqueue(ctx context.Context, email *Email) error
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]Email, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, sendErr string, nextAttemptAt time.Time, dead bool) error
+	GetByStatus(ctx context.Context, status string, limit, offset int) ([]Email, error)
+	Retry(ctx context.Context, id int64) error
+}
+
+// Email is an outbound email waiting in the outbox,
+// Data is the JSON encoded template data rendered in Locale.
+type Email struct {
+	ID            int64           `json:"id"`
+	Template      string          `json:"template"`
+	Locale        string          `json:"locale"`
+	Recipients    []string        `json:"recipients"`
+	Data          json.RawMessage `json:"-"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     *string         `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	SentAt        *time.Time      `json:"sent_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+type EmailStorage struct {
+	db *sql.DB
+}
+
+// EmailFunc builds the email sent to a user, it is enqueued in the same transaction
+// as the changes the email is about.
+type EmailFunc func(user *User) (*Email, error)
+
+// Enqueue stores a pending email, scan return data into Email instance.
+func (s *EmailStorage) Enqueue(ctx context.Context, email *Email) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// enqueueEmail stores a pending email within tx, scan return data into Email instance.
+func enqueueEmail(ctx context.Context, tx *sql.Tx, email *Email) error {
+	query := `
+	INSERT INTO email_outbox (template, locale, recipients, data)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, status, attempts, next_attempt_at, created_at, updated_at
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		email.Template,
+		email.Locale,
+		pq.Array(email.Recipients),
+		string(email.Data),
+	).Scan(
+		&email.ID,
+		&email.Status,
+		&email.Attempts,
+		&email.NextAttemptAt,
+		&email.CreatedAt,
+		&email.UpdatedAt,
+	)
+}
+
+// ClaimPending leases up to limit due emails for lease duration, so concurrent
+// workers never pick the same email. Emails leased by a crashed worker
+// become due again once the lease expires.
+func (s *EmailStorage) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]Email, error) {
+	query := `
+	UPDATE email_outbox SET locked_until = $3, attempts = attempts + 1, updated_at = now()
+	WHERE id IN (
+		SELECT id FROM email_outbox
+		WHERE status = $1 AND next_attempt_at <= now() AND (locked_until IS NULL OR locked_until < now())
+		ORDER BY next_attempt_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, template, locale, recipients, data, status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, EmailStatusPending, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEmails(rows)
+}
+
+// MarkSent marks the email as sent and drops its template data,
+// which may contain secrets such as activation tokens.
+func (s *EmailStorage) MarkSent(ctx context.Context, id int64) error {
+	query := `
+	UPDATE email_outbox
+	SET status = $2, data = '{}', last_error = NULL, locked_until = NULL, sent_at = now(), updated_at = now()
+	WHERE id = $1
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, EmailStatusSent)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkFailed records the sending error and schedules the next attempt,
+// or moves the email to dead-letter state if dead is true.
+func (s *EmailStorage) MarkFailed(ctx context.Context, id int64, sendErr string, nextAttemptAt time.Time, dead bool) error {
+	query := `
+	UPDATE email_outbox
+	SET status = $2, last_error = $3, next_attempt_at = $4, locked_until = NULL, updated_at = now()
+	WHERE id = $1
+`
+
+	status := EmailStatusPending
+	if dead {
+		status = EmailStatusDead
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, status, sendErr, nextAttemptAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByStatus lists emails with given status, most recent first.
+func (s *EmailStorage) GetByStatus(ctx context.Context, status string, limit, offset int) ([]Email, error) {
+	query := `
+	SELECT id, template, locale, recipients, data, status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at
+	FROM email_outbox
+	WHERE status = $1
+	ORDER BY updated_at DESC
+	LIMIT $2 OFFSET $3
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEmails(rows)
+}
+
+// Retry moves a dead email back to the queue with a fresh attempts counter.
+// If the email is not dead, the function will return ErrNotFound.
+func (s *EmailStorage) Retry(ctx context.Context, id int64) error {
+	query := `
+	UPDATE email_outbox
+	SET status = $2, attempts = 0, next_attempt_at = now(), updated_at = now()
+	WHERE id = $1 AND status = $3
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id, EmailStatusPending, EmailStatusDead)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func scanEmails(rows *sql.Rows) ([]Email, error) {
+	var emails []Email
+	for rows.Next() {
+		var (
+			e    Email
+			data []byte
+		)
+		if err := rows.Scan(
+			&e.ID,
+			&e.Template,
+			&e.Locale,
+			pq.Array(&e.Recipients),
+			&data,
+			&e.Status,
+			&e.Attempts,
+			&e.LastError,
+			&e.NextAttemptAt,
+			&e.SentAt,
+			&e.CreatedAt,
+			&e.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		e.Data = data
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
@@ -9,8 +9,14 @@ import (
 )
 
 type IFollower interface {
-	Follow(ctx context.Context, followerID, userID int64) error
+	Follow(ctx context.Context, followerID, userID int64) (bool, error)
 	Unfollow(ctx context.Context, followerID, userID int64) error
+	GetFollowers(ctx context.Context, userID, viewerID int64, q PaginatedFollowQuery) ([]FollowUser, Page, error)
+	GetFollowing(ctx context.Context, userID, viewerID int64, q PaginatedFollowQuery) ([]FollowUser, Page, error)
+	GetFollowRequests(ctx context.Context, userID int64, q PaginatedFollowQuery) ([]FollowRequest, Page, error)
+	AcceptFollowRequest(ctx context.Context, userID, requesterID int64) error
+	RejectFollowRequest(ctx context.Context, userID, requesterID int64) error
+	CanView(ctx context.Context, userID, viewerID int64) (bool, error)
 }
 
 type Follower struct {
@@ -19,45 +25,298 @@ type Follower struct {
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+// FollowUser is a user of a followers or following list,
+// IsFollowing reports whether the viewer follows them.
+type FollowUser struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	FollowedAt  time.Time `json:"followed_at"`
+	IsFollowing bool      `json:"is_following"`
+}
+
+// FollowRequest is a pending request of the user ID to follow a private account.
+type FollowRequest struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
 type FollowerStorage struct {
 	db *sql.DB
 }
 
-func (s *FollowerStorage) Follow(ctx context.Context, followerID, userID int64) error {
+// Follow makes followerID follow userID. If userID is a private account,
+// a follow request is created instead and the function returns true.
+// If one of the users blocked the other, the function will return ErrBlocked.
+func (s *FollowerStorage) Follow(ctx context.Context, followerID, userID int64) (bool, error) {
+	var requested bool
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// Lock both users, so the account cannot turn public or block the follower
+		// while the follow is pending
+		private, err := lockUsers(ctx, tx, followerID, userID)
+		if err != nil {
+			return err
+		}
+
+		isPrivate, ok := private[userID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		blocked, err := s.isBlocked(ctx, tx, followerID, userID)
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return ErrBlocked
+		}
+
+		if isPrivate {
+			requested = true
+			return s.createFollowRequest(ctx, tx, followerID, userID)
+		}
+
+		return s.follow(ctx, tx, followerID, userID)
+	})
+
+	return requested, err
+}
+
+// Unfollow removes the follow of followerID, or cancels its pending follow request.
+func (s *FollowerStorage) Unfollow(ctx context.Context, followerID, userID int64) error {
 	query := `
-		INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)
+		WITH f AS (
+			DELETE FROM followers WHERE user_id = $1 AND follower_id = $2 RETURNING 1
+		), r AS (
+			DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2 RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM f) + (SELECT COUNT(*) FROM r)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, userID, followerID)
+	var rows int64
+	if err := s.db.QueryRowContext(ctx, query, userID, followerID).Scan(&rows); err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetFollowRequests gets a page of pending requests to follow userID, most recent first.
+func (s *FollowerStorage) GetFollowRequests(ctx context.Context, userID int64, q PaginatedFollowQuery) ([]FollowRequest, Page, error) {
+	order, cmp := keysetOrder("desc", q.Cursor)
+
+	query := `
+		SELECT u.id, u.username, fr.created_at
+		FROM follow_requests fr
+		JOIN users u ON u.id = fr.requester_id
+		WHERE fr.user_id = $1 AND u.is_active = true AND u.deleted_at IS NULL
+		  AND ($2::timestamptz IS NULL OR (fr.created_at, u.id) ` + cmp + ` ($2, $3))
+		ORDER BY fr.created_at ` + order + `, u.id ` + order + `
+		LIMIT $4
+	`
+
+	after, afterID := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
 
+	rows, err := s.db.QueryContext(ctx, query, userID, after, afterID, q.Limit+1)
 	if err != nil {
-		var pqError *pq.Error
-		switch errors.As(err, &pqError) {
-		case pqError.Code == "23505":
-			return ErrConflict
-		case pqError.Code == "23514":
-			return ErrFollowSelf
-		default:
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	var requests []FollowRequest
+	for rows.Next() {
+		var fr FollowRequest
+		if err = rows.Scan(&fr.ID, &fr.Username, &fr.RequestedAt); err != nil {
+			return nil, Page{}, err
+		}
+
+		requests = append(requests, fr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	requests, page := paginate(requests, q.Limit, q.Cursor, func(fr FollowRequest) Cursor {
+		return Cursor{CreatedAt: fr.RequestedAt, ID: fr.ID}
+	})
+
+	return requests, page, nil
+}
+
+// AcceptFollowRequest turns the pending request of requesterID into a follow.
+// If there is no such request, the function will return ErrNotFound.
+func (s *FollowerStorage) AcceptFollowRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.deleteFollowRequest(ctx, tx, userID, requesterID); err != nil {
 			return err
 		}
+
+		err := s.follow(ctx, tx, requesterID, userID)
+		if errors.Is(err, ErrConflict) {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// RejectFollowRequest deletes the pending request of requesterID.
+// If there is no such request, the function will return ErrNotFound.
+func (s *FollowerStorage) RejectFollowRequest(ctx context.Context, userID, requesterID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.deleteFollowRequest(ctx, tx, userID, requesterID)
+	})
+}
+
+// CanView reports whether viewerID may see content of userID:
+// public accounts, private accounts followed by viewerID and their own content.
+func (s *FollowerStorage) CanView(ctx context.Context, userID, viewerID int64) (bool, error) {
+	query := `SELECT ` + visibleTo("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, userID, viewerID).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
+
+// GetFollowers gets a page of users following userID.
+func (s *FollowerStorage) GetFollowers(ctx context.Context, userID, viewerID int64, q PaginatedFollowQuery) ([]FollowUser, Page, error) {
+	return s.list(ctx, "f.follower_id", "f.user_id", userID, viewerID, q)
+}
+
+// GetFollowing gets a page of users followed by userID.
+func (s *FollowerStorage) GetFollowing(ctx context.Context, userID, viewerID int64, q PaginatedFollowQuery) ([]FollowUser, Page, error) {
+	return s.list(ctx, "f.user_id", "f.follower_id", userID, viewerID, q)
+}
+
+// list gets a page of users at column listed of follow edges whose column by is userID.
+func (s *FollowerStorage) list(ctx context.Context, listed, by string, userID, viewerID int64, q PaginatedFollowQuery) ([]FollowUser, Page, error) {
+	order, cmp := keysetOrder("desc", q.Cursor)
+
+	query := `
+		SELECT u.id, u.username, f.created_at,
+		       EXISTS (SELECT 1 FROM followers v WHERE v.user_id = u.id AND v.follower_id = $2)
+		FROM followers f
+		JOIN users u ON u.id = ` + listed + `
+		WHERE ` + by + ` = $1 AND u.is_active = true AND u.deleted_at IS NULL
+		  AND ($3::timestamptz IS NULL OR (f.created_at, u.id) ` + cmp + ` ($3, $4))
+		ORDER BY f.created_at ` + order + `, u.id ` + order + `
+		LIMIT $5
+	`
+
+	after, afterID := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// Fetch one more user to know whether there is another page
+	rows, err := s.db.QueryContext(ctx, query, userID, viewerID, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	var users []FollowUser
+	for rows.Next() {
+		var u FollowUser
+		if err = rows.Scan(&u.ID, &u.Username, &u.FollowedAt, &u.IsFollowing); err != nil {
+			return nil, Page{}, err
+		}
+
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	users, page := paginate(users, q.Limit, q.Cursor, func(u FollowUser) Cursor {
+		return Cursor{CreatedAt: u.FollowedAt, ID: u.ID}
+	})
+
+	return users, page, nil
+}
+
+func (s *FollowerStorage) isBlocked(ctx context.Context, tx *sql.Tx, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedBetween("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := tx.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func (s *FollowerStorage) follow(ctx context.Context, tx *sql.Tx, followerID, userID int64) error {
+	query := `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, query, userID, followerID); err != nil {
+		return followError(err)
 	}
 
 	return nil
 }
 
-func (s *FollowerStorage) Unfollow(ctx context.Context, followerID, userID int64) error {
+func (s *FollowerStorage) createFollowRequest(ctx context.Context, tx *sql.Tx, requesterID, userID int64) error {
+	// Followers of the account have nothing to request
 	query := `
-		DELETE FROM followers 
-		WHERE user_id = $1 AND follower_id = $2
+		INSERT INTO follow_requests (user_id, requester_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	result, err := s.db.ExecContext(ctx, query, userID, followerID)
+	result, err := tx.ExecContext(ctx, query, userID, requesterID)
+	if err != nil {
+		return followError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+func (s *FollowerStorage) deleteFollowRequest(ctx context.Context, tx *sql.Tx, userID, requesterID int64) error {
+	query := `DELETE FROM follow_requests WHERE user_id = $1 AND requester_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, query, userID, requesterID)
 	if err != nil {
 		return err
 	}
@@ -71,5 +330,64 @@ func (s *FollowerStorage) Unfollow(ctx context.Context, followerID, userID int64
 		return ErrNotFound
 	}
 
-	return err
+	return nil
+}
+
+// lockUsers locks active users in id order, like the followers counters trigger does,
+// so that transactions locking the same users cannot deadlock.
+// It returns whether each locked user is a private account.
+func lockUsers(ctx context.Context, tx *sql.Tx, ids ...int64) (map[int64]bool, error) {
+	query := `SELECT id, is_private FROM users WHERE id = ANY($1) AND is_active = true AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	private := make(map[int64]bool, len(ids))
+	for rows.Next() {
+		var (
+			id        int64
+			isPrivate bool
+		)
+		if err = rows.Scan(&id, &isPrivate); err != nil {
+			return nil, err
+		}
+
+		private[id] = isPrivate
+	}
+
+	return private, rows.Err()
+}
+
+// followError maps constraint violations of followers and follow_requests tables.
+func followError(err error) error {
+	var pqError *pq.Error
+	if !errors.As(err, &pqError) {
+		return err
+	}
+
+	switch pqError.Code {
+	case "23505":
+		return ErrConflict
+	case "23514":
+		return ErrFollowSelf
+	case "23503":
+		return ErrNotFound
+	default:
+		return err
+	}
+}
+
+// visibleTo returns the SQL condition matching content of the author column
+// visible to the viewer column: public accounts, private accounts followed by the viewer,
+// and the viewer's own content.
+func visibleTo(author, viewer string) string {
+	return `(` + author + ` = ` + viewer + `
+		OR NOT EXISTS (SELECT 1 FROM users pu WHERE pu.id = ` + author + ` AND pu.is_private)
+		OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = ` + author + ` AND vf.follower_id = ` + viewer + `))`
 }
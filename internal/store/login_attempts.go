@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ILoginAttempts interface {
+	CountFailedByIP(ctx context.Context, ip string, window time.Duration) (int, error)
+	RecordFailure(ctx context.Context, userID int64, ip string, maxAttempts int, window, lockout time.Duration) error
+	Reset(ctx context.Context, userID int64) error
+	DeleteExpired(ctx context.Context, window time.Duration) (int64, error)
+}
+
+type LoginAttemptStorage struct {
+	db *sql.DB
+}
+
+// CountFailedByIP counts failed login attempts coming from given IP address
+// within the last window duration.
+func (s *LoginAttemptStorage) CountFailedByIP(ctx context.Context, ip string, window time.Duration) (int, error) {
+	query := `
+	SELECT COUNT(*) FROM login_attempts
+	WHERE ip_address = $1 AND created_at > $2
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, ip, time.Now().Add(-window)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RecordFailure stores a failed login attempt for given IP address and,
+// if userID is known, increases the failed attempts counter of the user.
+// The counter restarts when the previous failure is older than window.
+// Once the counter reaches maxAttempts the account is locked for lockout duration
+// and ErrAccountLocked is returned.
+func (s *LoginAttemptStorage) RecordFailure(ctx context.Context, userID int64, ip string, maxAttempts int, window, lockout time.Duration) error {
+	var locked bool
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Insert attempt, user_id is null when the email does not exist
+		if err := s.create(ctx, tx, userID, ip); err != nil {
+			return err
+		}
+
+		if userID == 0 {
+			return nil
+		}
+
+		// 2. Increase counter and lock the account when reaching max attempts
+		var err error
+		locked, err = s.increaseUserFailures(ctx, tx, userID, maxAttempts, window, lockout)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		return ErrAccountLocked
+	}
+
+	return nil
+}
+
+// Reset clears failed attempts counter and lock of the user after a successful login.
+func (s *LoginAttemptStorage) Reset(ctx context.Context, userID int64) error {
+	query := `UPDATE users SET failed_login_attempts = 0, last_failed_login_at = NULL, locked_until = NULL WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpired purges attempts older than window, which are no longer counted,
+// returns number of deleted rows.
+func (s *LoginAttemptStorage) DeleteExpired(ctx context.Context, window time.Duration) (int64, error) {
+	query := `DELETE FROM login_attempts WHERE created_at <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-window))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *LoginAttemptStorage) create(ctx context.Context, tx *sql.Tx, userID int64, ip string) error {
+	query := `INSERT INTO login_attempts (user_id, ip_address) VALUES ($1, $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	id := sql.NullInt64{Int64: userID, Valid: userID != 0}
+
+	_, err := tx.ExecContext(ctx, query, id, ip)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *LoginAttemptStorage) increaseUserFailures(ctx context.Context, tx *sql.Tx, userID int64, maxAttempts int, window, lockout time.Duration) (bool, error) {
+	// Failures before the window are forgotten
+	failures := `(CASE WHEN last_failed_login_at > $4 THEN failed_login_attempts ELSE 0 END + 1)`
+
+	// The counter restarts from zero whenever the account gets locked
+	query := `
+	UPDATE users
+	SET failed_login_attempts = CASE WHEN ` + failures + ` >= $2 THEN 0 ELSE ` + failures + ` END,
+	    locked_until = CASE WHEN ` + failures + ` >= $2 THEN $3 ELSE locked_until END,
+	    last_failed_login_at = now()
+	WHERE id = $1
+	RETURNING COALESCE(locked_until > now(), false)
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	now := time.Now()
+
+	var locked bool
+	err := tx.QueryRowContext(ctx, query, userID, maxAttempts, now.Add(lockout), now.Add(-window)).Scan(&locked)
+	if err != nil {
+		return false, err
+	}
+
+	return locked, nil
+}
@@ -23,12 +23,39 @@ func (m *MockUserStore) GetByID(ctx context.Context, userID int64) (*User, error
 func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
 	return &User{}, nil
 }
-func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error {
+func (m *MockUserStore) UpdateSettings(ctx context.Context, user *User) error {
+	return nil
+}
+func (m *MockUserStore) GetProfile(ctx context.Context, id, viewerID int64) (*UserProfile, error) {
+	return &UserProfile{ID: id}, nil
+}
+func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration, invitation EmailFunc) error {
 	return nil
 }
 func (m *MockUserStore) Activate(ctx context.Context, t string) error {
 	return nil
 }
-func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
+func (m *MockUserStore) Delete(ctx context.Context, id int64) ([]string, error) {
+	return nil, nil
+}
+func (m *MockUserStore) Restore(ctx context.Context, id int64) error {
 	return nil
 }
+func (m *MockUserStore) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+func (m *MockUserStore) ResendInvitation(ctx context.Context, email, token string, exp, cooldown time.Duration, invitation EmailFunc) (*User, error) {
+	return &User{Email: email}, nil
+}
+func (m *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+func (m *MockUserStore) DeleteUnactivated(ctx context.Context, olderThan time.Duration) (int64, error) {
+	return 0, nil
+}
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+func (m *MockUserStore) ResetPassword(ctx context.Context, token, newPassword string) ([]string, error) {
+	return nil, nil
+}
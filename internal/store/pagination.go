@@ -1,18 +1,312 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at a row of a list ordered by (created_at, id).
+// The page starts right after the row, or right before it if Prev is true.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+	Prev      bool
+}
+
+// Encode returns the opaque representation of the cursor sent to clients.
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(c.ID, 10)
+	if c.Prev {
+		raw += ",prev"
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor returned by Cursor.Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if c.ID, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if len(parts) == 3 {
+		if parts[2] != "prev" {
+			return Cursor{}, ErrInvalidCursor
+		}
+		c.Prev = true
+	}
+
+	return c, nil
+}
+
+// Page holds cursors of the pages around a list of rows,
+// a cursor is empty if there is no such page.
+type Page struct {
+	Next string
+	Prev string
+}
+
+// keysetOrder returns the ORDER BY direction and the comparison operator selecting
+// rows after cursor, for a list sorted by sort ("asc" or "desc").
+// Rows before a Prev cursor are fetched in reverse order, then restored by paginate.
+func keysetOrder(sort string, cursor *Cursor) (order, cmp string) {
+	asc := sort == "asc"
+	if cursor != nil && cursor.Prev {
+		asc = !asc
+	}
+
+	if asc {
+		return "ASC", ">"
+	}
+
+	return "DESC", "<"
+}
+
+// keysetArgs returns the cursor position as query arguments, NULL if there is no cursor.
+func keysetArgs(cursor *Cursor) (*time.Time, int64) {
+	if cursor == nil {
+		return nil, 0
+	}
+
+	return &cursor.CreatedAt, cursor.ID
+}
+
+// paginate trims rows fetched with limit+1 to detect another page, restores the
+// order of rows fetched before a Prev cursor, and returns cursors around the rows.
+func paginate[T any](rows []T, limit int, cursor *Cursor, key func(T) Cursor) ([]T, Page) {
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+
+	backward := cursor != nil && cursor.Prev
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, Page{}
+	}
+
+	var page Page
+
+	// Going backward, the page we came from is after these rows
+	if more || backward {
+		next := key(rows[len(rows)-1])
+		page.Next = next.Encode()
+	}
+
+	if (cursor != nil && !backward) || (backward && more) {
+		prev := key(rows[0])
+		prev.Prev = true
+		page.Prev = prev.Encode()
+	}
+
+	return rows, page
+}
+
+// PaginatedCommentQuery lists top level comments of a post,
+// or replies of ParentID, starting after Cursor.
+type PaginatedCommentQuery struct {
+	Limit    int     `json:"limit" validate:"omitempty,min=1,max=100"`
+	ParentID *int64  `json:"parent_id" validate:"omitempty,min=1"`
+	Cursor   *Cursor `json:"-"`
+}
+
+func (q *PaginatedCommentQuery) Parse(r *http.Request) error {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		q.Limit = v
+	}
+
+	parentID := qr.Get("parent_id")
+	if parentID != "" {
+		v, err := strconv.ParseInt(parentID, 10, 64)
+		if err != nil {
+			return err
+		}
+		q.ParentID = &v
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.Cursor = &c
+	}
+
+	return nil
+}
+
+// PaginatedFollowQuery lists followers or followed users, most recently followed first.
+type PaginatedFollowQuery struct {
+	Limit  int     `json:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor *Cursor `json:"-"`
+}
+
+func (q *PaginatedFollowQuery) Parse(r *http.Request) error {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		q.Limit = v
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.Cursor = &c
+	}
+
+	return nil
+}
+
+// PaginatedBookmarkQuery lists bookmarked posts, most recently bookmarked first.
+type PaginatedBookmarkQuery struct {
+	Limit  int     `json:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor *Cursor `json:"-"`
+}
+
+func (q *PaginatedBookmarkQuery) Parse(r *http.Request) error {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		q.Limit = v
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.Cursor = &c
+	}
+
+	return nil
+}
+
+// PaginatedRevisionQuery lists past versions of a post, latest first.
+type PaginatedRevisionQuery struct {
+	Limit  int     `json:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor *Cursor `json:"-"`
+}
+
+func (q *PaginatedRevisionQuery) Parse(r *http.Request) error {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		q.Limit = v
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.Cursor = &c
+	}
+
+	return nil
+}
+
+// PaginatedUserPostQuery lists posts of a user in Status, or in any status if empty,
+// most recently created first.
+type PaginatedUserPostQuery struct {
+	Limit  int     `json:"limit" validate:"omitempty,min=1,max=100"`
+	Status string  `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	Cursor *Cursor `json:"-"`
+}
+
+func (q *PaginatedUserPostQuery) Parse(r *http.Request) error {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		q.Limit = v
+	}
+
+	status := qr.Get("status")
+	if status != "" {
+		q.Status = status
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return err
+		}
+		q.Cursor = &c
+	}
+
+	return nil
+}
+
 type PaginatedFeedQuery struct {
-	Limit  int    `json:"limit" validate:"omitempty,min=1,max=100"`
-	Offset int    `json:"offset" validate:"omitempty,min=0"`
-	Sort   string `json:"sort" validate:"omitempty,oneof=asc desc"`
-	Search string `json:"search" validate:"omitempty,lte=100"`
-	Since  string `json:"since"`
-	Until  string `json:"until"`
+	Limit    int        `json:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor   *Cursor    `json:"-"`
+	Sort     string     `json:"sort" validate:"omitempty,oneof=asc desc"`
+	Search   string     `json:"search" validate:"omitempty,lte=100"`
+	Since    *time.Time `json:"since"`
+	Until    *time.Time `json:"until"`
+	Tags     []string   `json:"tags" validate:"omitempty,max=10,dive,lte=100"`
+	TagMatch string     `json:"tag_match" validate:"omitempty,oneof=any all"`
+	Author   string     `json:"author" validate:"omitempty,lte=255"`
 }
 
 func (q *PaginatedFeedQuery) Parse(r *http.Request) error {
@@ -26,12 +320,40 @@ func (q *PaginatedFeedQuery) Parse(r *http.Request) error {
 
 	since := qr.Get("since")
 	if since != "" {
-		q.Since = parseTime(since)
+		if q.Since, err = parseTime("since", since); err != nil {
+			return err
+		}
 	}
 
 	until := qr.Get("until")
 	if until != "" {
-		q.Until = parseTime(until)
+		if q.Until, err = parseTime("until", until); err != nil {
+			return err
+		}
+	}
+
+	if q.Since != nil && q.Until != nil && !q.Since.Before(*q.Until) {
+		return errors.New("since must be before until")
+	}
+
+	tags := qr.Get("tags")
+	if tags != "" {
+		q.Tags = nil
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				q.Tags = append(q.Tags, tag)
+			}
+		}
+	}
+
+	tagMatch := qr.Get("tag_match")
+	if tagMatch != "" {
+		q.TagMatch = tagMatch
+	}
+
+	author := qr.Get("author")
+	if author != "" {
+		q.Author = author
 	}
 
 	limit := qr.Get("limit")
@@ -42,12 +364,13 @@ func (q *PaginatedFeedQuery) Parse(r *http.Request) error {
 		}
 	}
 
-	offset := qr.Get("offset")
-	if offset != "" {
-		q.Offset, err = strconv.Atoi(offset)
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
 		if err != nil {
 			return err
 		}
+		q.Cursor = &c
 	}
 
 	sort := qr.Get("sort")
@@ -57,11 +380,54 @@ func (q *PaginatedFeedQuery) Parse(r *http.Request) error {
 	return nil
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
-	if err != nil {
-		return ""
+// parseTime parses the value of the time parameter, either in RFC 3339
+// or in "2006-01-02 15:04:05" format which is read as UTC.
+func parseTime(param, s string) (*time.Time, error) {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid %s %q, expected RFC 3339 (2006-01-02T15:04:05Z07:00), %q or %q format",
+		param, s, time.DateTime, time.DateOnly)
+}
+
+// PaginatedSearchQuery searches Type results ("all", "post", "comment" or "user")
+// matching Query, best ranked first.
+type PaginatedSearchQuery struct {
+	Query  string `json:"q" validate:"required,lte=100"`
+	Type   string `json:"type" validate:"oneof=all post comment user"`
+	Limit  int    `json:"limit" validate:"min=1,max=100"`
+	Offset int    `json:"offset" validate:"min=0"`
+}
+
+func (q *PaginatedSearchQuery) Parse(r *http.Request) error {
+	var err error
+	qr := r.URL.Query()
+
+	q.Query = strings.TrimSpace(qr.Get("q"))
+
+	typ := qr.Get("type")
+	if typ != "" {
+		q.Type = typ
+	}
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+	}
+
+	offset := qr.Get("offset")
+	if offset != "" {
+		q.Offset, err = strconv.Atoi(offset)
+		if err != nil {
+			return err
+		}
 	}
 
-	return t.Format(time.DateTime)
+	return nil
 }
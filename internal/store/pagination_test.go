@@ -0,0 +1,204 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	createdAt := time.Date(2024, 10, 7, 12, 30, 0, 123456789, time.FixedZone("ICT", 7*60*60))
+
+	tests := []Cursor{
+		{CreatedAt: createdAt, ID: 42},
+		{CreatedAt: createdAt, ID: 42, Prev: true},
+		{CreatedAt: time.Unix(0, 0), ID: 1},
+	}
+
+	for _, c := range tests {
+		got, err := DecodeCursor(c.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%v) error = %v", c, err)
+		}
+
+		if !got.CreatedAt.Equal(c.CreatedAt) || got.ID != c.ID || got.Prev != c.Prev {
+			t.Errorf("DecodeCursor(Encode(%v)) = %v", c, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"missing id", encode("2024-10-07T12:30:00Z")},
+		{"invalid time", encode("yesterday,1")},
+		{"invalid id", encode("2024-10-07T12:30:00Z,abc")},
+		{"invalid direction", encode("2024-10-07T12:30:00Z,1,next")},
+		{"too many parts", encode("2024-10-07T12:30:00Z,1,prev,x")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor() error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestKeysetOrder(t *testing.T) {
+	prev := &Cursor{Prev: true}
+
+	tests := []struct {
+		sort      string
+		cursor    *Cursor
+		order     string
+		cmp       string
+		direction string
+	}{
+		{"desc", nil, "DESC", "<", "first page"},
+		{"asc", nil, "ASC", ">", "first page"},
+		{"desc", &Cursor{}, "DESC", "<", "next page"},
+		{"desc", prev, "ASC", ">", "previous page"},
+		{"asc", prev, "DESC", "<", "previous page"},
+	}
+
+	for _, tt := range tests {
+		order, cmp := keysetOrder(tt.sort, tt.cursor)
+		if order != tt.order || cmp != tt.cmp {
+			t.Errorf("keysetOrder(%s, %s) = %s %s, want %s %s", tt.sort, tt.direction, order, cmp, tt.order, tt.cmp)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	base := time.Date(2024, 10, 7, 0, 0, 0, 0, time.UTC)
+	key := func(id int64) Cursor {
+		return Cursor{CreatedAt: base.Add(time.Duration(id) * time.Minute), ID: id}
+	}
+	cursor := func(id int64, prev bool) *Cursor {
+		c := key(id)
+		c.Prev = prev
+		return &c
+	}
+	encode := func(id int64, prev bool) string {
+		return cursor(id, prev).Encode()
+	}
+
+	tests := []struct {
+		name   string
+		rows   []int64
+		limit  int
+		cursor *Cursor
+		want   []int64
+		page   Page
+	}{
+		{
+			name:  "empty",
+			rows:  []int64{},
+			limit: 2,
+			want:  []int64{},
+		},
+		{
+			name:  "single page",
+			rows:  []int64{5, 4},
+			limit: 2,
+			want:  []int64{5, 4},
+		},
+		{
+			name:  "first page of many",
+			rows:  []int64{5, 4, 3},
+			limit: 2,
+			want:  []int64{5, 4},
+			page:  Page{Next: encode(4, false)},
+		},
+		{
+			name:   "middle page",
+			rows:   []int64{3, 2, 1},
+			limit:  2,
+			cursor: cursor(4, false),
+			want:   []int64{3, 2},
+			page:   Page{Next: encode(2, false), Prev: encode(3, true)},
+		},
+		{
+			name:   "last page",
+			rows:   []int64{1},
+			limit:  2,
+			cursor: cursor(2, false),
+			want:   []int64{1},
+			page:   Page{Prev: encode(1, true)},
+		},
+		{
+			// Rows before a Prev cursor are fetched in reverse order
+			name:   "previous page with more before",
+			rows:   []int64{3, 4, 5},
+			limit:  2,
+			cursor: cursor(2, true),
+			want:   []int64{4, 3},
+			page:   Page{Next: encode(3, false), Prev: encode(4, true)},
+		},
+		{
+			name:   "previous page back to the first",
+			rows:   []int64{4, 5},
+			limit:  2,
+			cursor: cursor(3, true),
+			want:   []int64{5, 4},
+			page:   Page{Next: encode(4, false)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, page := paginate(tt.rows, tt.limit, tt.cursor, key)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paginate() rows = %v, want %v", got, tt.want)
+			}
+
+			if page != tt.page {
+				t.Errorf("paginate() page = %+v, want %+v", page, tt.page)
+			}
+		})
+	}
+}
+
+func TestPaginatedFeedQueryParse(t *testing.T) {
+	r := httptest.NewRequest("GET", "/feed?tags=go,%20sql,,&since=2024-10-01&until=2024-10-07T00:00:00Z&limit=5&sort=asc", nil)
+
+	q := PaginatedFeedQuery{Limit: 20, Sort: "desc"}
+	if err := q.Parse(r); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(q.Tags, []string{"go", "sql"}) {
+		t.Errorf("Parse() tags = %q, want [go sql]", q.Tags)
+	}
+
+	if q.Limit != 5 || q.Sort != "asc" {
+		t.Errorf("Parse() limit, sort = %d %s, want 5 asc", q.Limit, q.Sort)
+	}
+
+	if q.Since == nil || !q.Since.Equal(time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Parse() since = %v", q.Since)
+	}
+
+	r = httptest.NewRequest("GET", "/feed?since=2024-10-07&until=2024-10-01", nil)
+	if err := (&PaginatedFeedQuery{}).Parse(r); err == nil {
+		t.Errorf("Parse() accepted since after until")
+	}
+
+	r = httptest.NewRequest("GET", "/feed?cursor=invalid", nil)
+	if err := (&PaginatedFeedQuery{}).Parse(r); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Parse() error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
@@ -3,31 +3,53 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"github.com/lib/pq"
 	"time"
 )
 
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type IPosts interface {
 	GetByID(context.Context, int64) (*Post, error)
 	Create(context.Context, *Post) error
 	Update(context.Context, *Post) error
 	Delete(context.Context, int64) error
-	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+	Restore(context.Context, int64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, Page, error)
+	GetByUserID(context.Context, int64, PaginatedUserPostQuery) ([]PostWithMetadata, Page, error)
+	PublishDue(context.Context) (int64, error)
+	GetRevisions(context.Context, int64, PaginatedRevisionQuery) ([]PostRevision, Page, error)
+	GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error)
 }
 
 // Post model
 type Post struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Tags      []string  `json:"tags"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments,omitempty"`
-	User      struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Title  string `json:"title"`
+	// Content is the Markdown source of the post, rendered as ContentHTML.
+	// Lists of posts only have the Excerpt.
+	Content     string   `json:"content,omitempty"`
+	ContentHTML string   `json:"content_html,omitempty"`
+	Excerpt     string   `json:"excerpt"`
+	Tags        []string `json:"tags"`
+	Status      string   `json:"status"`
+	// PublishAt is when a scheduled post will be published, or when the post was published
+	PublishAt *time.Time `json:"publish_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"`
+	// AttachmentIDs replaces the attachments of the post on Create and Update, nil keeps them
+	AttachmentIDs []int64      `json:"-"`
+	Attachments   []Attachment `json:"attachments"`
+	User          struct {
 		Username string `json:"username,omitempty"`
 	} `json:"user,omitempty"`
 }
@@ -39,36 +61,69 @@ type PostStorage struct {
 type PostWithMetadata struct {
 	Post
 	CommentCounts int `json:"comment_counts"`
+	// ReactionCounts maps reaction kinds to their count, kinds nobody used are omitted
+	ReactionCounts map[string]int `json:"reaction_counts"`
+	// ViewerReaction is the reaction of the user reading the feed, if any
+	ViewerReaction *string `json:"viewer_reaction"`
 }
 
-// GetUserFeed gets posts from followed user and user itself,
-// with associated username, and comment counts,
-// limited by PaginatedFeedQuery
-func (s *PostStorage) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+// GetUserFeed gets published posts from followed user and user itself,
+// with associated username, comment counts, reaction counts and the reaction of userID,
+// a page of fq.Limit posts around fq.Cursor matching the filters of fq, ordered by publication time.
+// Posts of users muted by userID are hidden.
+func (s *PostStorage) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, Page, error) {
+	order, cmp := keysetOrder(fq.Sort, fq.Cursor)
+
+	// Posts having any of the tags by default, or all of them
+	tagOp := "&&"
+	if fq.TagMatch == "all" {
+		tagOp = "@>"
+	}
+
 	// Get posts from followed user and user itself
 	query := `
-		SELECT 
-			p.id, p.user_id, p.title, p.content, p.created_at, p.updated_at, p.version, p.tags,
-			u.username,
-			COUNT(c.id) AS comments_count
+		SELECT ` + postMetadataColumns("$1") + `
 		FROM posts p
-		LEFT JOIN comments c ON c.post_id = p.id
 		LEFT JOIN users u ON p.user_id = u.id
 		LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
-		WHERE (f.follower_id = $1 OR p.user_id = $1) AND (
-		    (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%'))
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + fq.Sort + `
-		LIMIT $2 OFFSET $3
+		WHERE (f.follower_id = $1 OR p.user_id = $1) AND p.status = 'published'
+		  AND p.deleted_at IS NULL AND u.deleted_at IS NULL AND (
+		    (p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%'))
+		  AND ($4::timestamptz IS NULL OR (p.publish_at, p.id) ` + cmp + ` ($4, $5))
+		  AND ($6::timestamptz IS NULL OR p.publish_at >= $6)
+		  AND ($7::timestamptz IS NULL OR p.publish_at < $7)
+		  AND ($8::varchar[] IS NULL OR cardinality($8::varchar[]) = 0 OR p.tags ` + tagOp + ` $8::varchar[])
+		  AND ($9 = '' OR u.username = $9)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM user_restrictions m
+		      WHERE m.user_id = $1 AND m.target_id = p.user_id AND m.kind = 'mute'
+		  )
+		ORDER BY p.publish_at ` + order + `, p.id ` + order + `
+		LIMIT $2
 	`
 
+	after, afterID := keysetArgs(fq.Cursor)
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search)
+	// Fetch one more post to know whether there is another page
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		userID,
+		fq.Limit+1,
+		fq.Search,
+		after,
+		afterID,
+		fq.Since,
+		fq.Until,
+		pq.Array(fq.Tags),
+		fq.Author,
+	)
 
 	if err != nil {
-		return nil, err
+		return nil, Page{}, err
 	}
 
 	defer rows.Close()
@@ -76,39 +131,152 @@ func (s *PostStorage) GetUserFeed(ctx context.Context, userID int64, fq Paginate
 	var feed []PostWithMetadata
 	for rows.Next() {
 		var post PostWithMetadata
+		if err = scanPostWithMetadata(rows, &post); err != nil {
+			return nil, Page{}, err
+		}
 
-		err = rows.Scan(
-			&post.ID,
-			&post.UserID,
-			&post.Title,
-			&post.Content,
-			&post.CreatedAt,
-			&post.UpdatedAt,
-			&post.Version,
-			pq.Array(&post.Tags),
-			&post.User.Username,
-			&post.CommentCounts,
-		)
+		feed = append(feed, post)
+	}
 
-		if err != nil {
-			return nil, err
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	feed, page := paginate(feed, fq.Limit, fq.Cursor, func(p PostWithMetadata) Cursor {
+		return Cursor{CreatedAt: *p.PublishAt, ID: p.ID}
+	})
+
+	return feed, page, nil
+}
+
+// GetByUserID lists posts of the user in q.Status, or in any status,
+// most recently created first.
+func (s *PostStorage) GetByUserID(ctx context.Context, userID int64, q PaginatedUserPostQuery) ([]PostWithMetadata, Page, error) {
+	order, cmp := keysetOrder("desc", q.Cursor)
+
+	query := `
+		SELECT ` + postMetadataColumns("$1") + `
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL
+		  AND ($2 = '' OR p.status = $2)
+		  AND ($3::timestamptz IS NULL OR (p.created_at, p.id) ` + cmp + ` ($3, $4))
+		ORDER BY p.created_at ` + order + `, p.id ` + order + `
+		LIMIT $5
+	`
+
+	after, afterID := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// Fetch one more post to know whether there is another page
+	rows, err := s.db.QueryContext(ctx, query, userID, q.Status, after, afterID, q.Limit+1)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	var posts []PostWithMetadata
+	for rows.Next() {
+		var post PostWithMetadata
+		if err = scanPostWithMetadata(rows, &post); err != nil {
+			return nil, Page{}, err
 		}
 
-		feed = append(feed, post)
+		posts = append(posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	posts, page := paginate(posts, q.Limit, q.Cursor, func(p PostWithMetadata) Cursor {
+		return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	})
+
+	return posts, page, nil
+}
+
+// PublishDue publishes scheduled posts whose publish_at has come,
+// returns number of published posts.
+func (s *PostStorage) PublishDue(ctx context.Context) (int64, error) {
+	query := `
+	UPDATE posts SET status = 'published', updated_at = NOW(), version = version + 1
+	WHERE status = 'scheduled' AND publish_at <= now() AND deleted_at IS NULL
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
 	}
 
-	return feed, nil
+	return result.RowsAffected()
+}
+
+// postMetadataColumns returns the columns scanned by scanPostWithMetadata,
+// from posts p joined with their author u, for the viewer column.
+func postMetadataColumns(viewer string) string {
+	return `p.id, p.user_id, p.title, p.excerpt, p.created_at, p.updated_at, p.version, p.tags,
+		p.status, p.publish_at, u.username,
+		(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,
+		COALESCE((
+		    SELECT jsonb_object_agg(r.kind, r.count)
+		    FROM (SELECT kind, COUNT(*) AS count FROM post_reactions WHERE post_id = p.id GROUP BY kind) r
+		), '{}') AS reaction_counts,
+		(SELECT kind FROM post_reactions WHERE post_id = p.id AND user_id = ` + viewer + `) AS viewer_reaction,
+		` + attachmentsColumn + ` AS attachments`
+}
+
+// scanPostWithMetadata scans a row selecting postMetadataColumns, followed by extra columns.
+func scanPostWithMetadata(rows *sql.Rows, post *PostWithMetadata, extra ...any) error {
+	var reactionCounts, attachments []byte
+
+	dest := []any{
+		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Excerpt,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.Version,
+		pq.Array(&post.Tags),
+		&post.Status,
+		&post.PublishAt,
+		&post.User.Username,
+		&post.CommentCounts,
+		&reactionCounts,
+		&post.ViewerReaction,
+		&attachments,
+	}
+
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	if post.Attachments, err = unmarshalAttachments(attachments); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(reactionCounts, &post.ReactionCounts)
 }
 
 // GetByID gets a post by given ID, return a pointer to Post.
-// If the query select no rows, the function will return ErrNotFound.
+// If the query select no rows, or the post or its author is deleted,
+// the function will return ErrNotFound.
 func (s *PostStorage) GetByID(ctx context.Context, id int64) (*Post, error) {
 	var post Post
+	var attachments []byte
 
 	query := `
-	SELECT id, title, user_id, content, tags, created_at, updated_at, version 
-	FROM posts 
-	WHERE id = $1;
+	SELECT id, title, user_id, content, content_html, excerpt, tags, status, publish_at, created_at, updated_at, version,
+	       ` + attachmentsColumn + `
+	FROM posts p
+	WHERE id = $1 AND deleted_at IS NULL AND ` + authorNotDeleted("p.user_id") + `;
 `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
@@ -118,10 +286,15 @@ func (s *PostStorage) GetByID(ctx context.Context, id int64) (*Post, error) {
 		&post.Title,
 		&post.UserID,
 		&post.Content,
+		&post.ContentHTML,
+		&post.Excerpt,
 		pq.Array(&post.Tags),
+		&post.Status,
+		&post.PublishAt,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Version,
+		&attachments,
 	)
 
 	if err != nil {
@@ -133,85 +306,170 @@ func (s *PostStorage) GetByID(ctx context.Context, id int64) (*Post, error) {
 		}
 	}
 
+	if post.Attachments, err = unmarshalAttachments(attachments); err != nil {
+		return nil, err
+	}
+
 	return &post, nil
 }
 
 // Create creates a post with provided data, scan return data into Post instance.
+// Attachments of post.AttachmentIDs are attached to the post in the same transaction,
+// returns ErrUnknownAttachment if any of them cannot be attached.
 func (s *PostStorage) Create(ctx context.Context, post *Post) error {
-	query := `
-	INSERT INTO posts (user_id, title, content, tags)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, updated_at
-`
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `
+		INSERT INTO posts (user_id, title, content, content_html, excerpt, tags, status, publish_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.UserID,
+			post.Title,
+			post.Content,
+			post.ContentHTML,
+			post.Excerpt,
+			pq.Array(post.Tags),
+			post.Status,
+			post.PublishAt,
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		if post.AttachmentIDs == nil {
+			post.Attachments = []Attachment{}
+			return nil
+		}
+
+		return attach(ctx, tx, post)
+	})
+}
+
+// Update updates a post with specific ID and version, scan return data into Post instance
+// or return ErrNotFound if the post does not exist or its version changed.
+// The replaced version is saved as a revision in the same transaction,
+// and the attachments are replaced by post.AttachmentIDs unless it is nil.
+func (s *PostStorage) Update(ctx context.Context, post *Post) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if err := s.createRevision(ctx, tx, post.ID, post.Version); err != nil {
+			return err
+		}
+
+		query := `
+		UPDATE posts 
+		SET title = $1, content = $2, tags = $3, status = $6, publish_at = $7, content_html = $8, excerpt = $9,
+		    updated_at = NOW(), version = version + 1 
+		WHERE id = $4 and version = $5 AND deleted_at IS NULL
+		RETURNING version, updated_at
+	`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			pq.Array(post.Tags),
+			post.ID,
+			post.Version,
+			post.Status,
+			post.PublishAt,
+			post.ContentHTML,
+			post.Excerpt,
+		).Scan(&post.Version, &post.UpdatedAt)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if post.AttachmentIDs == nil {
+			return nil
+		}
+
+		return attach(ctx, tx, post)
+	})
+}
+
+// Delete moves a post to the trash, it is purged after the retention period.
+// Returns ErrNotFound if the post does not exist or is already deleted.
+func (s *PostStorage) Delete(ctx context.Context, id int64) error {
+	query := `UPDATE posts SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.UserID,
-		post.Title,
-		post.Content,
-		pq.Array(post.Tags),
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
 
+	rows, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if rows != 1 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
-// Update updates a post with specific ID, scan return data into Post instance
-// or return ErrNotFound if there is no rows in query.
-func (s *PostStorage) Update(ctx context.Context, post *Post) error {
-	query := `
-	UPDATE posts 
-	SET title = $1, content = $2, version = version + 1 
-	WHERE id = $3 and version = $4	
-	RETURNING version
-`
+// Restore takes a post out of the trash, returns ErrNotFound if it is not deleted.
+func (s *PostStorage) Restore(ctx context.Context, id int64) error {
+	query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.ID,
-		post.Version,
-	).Scan(&post.Version)
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
 
+	rows, err := result.RowsAffected()
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
-		}
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// Delete deletes a post instance by given ID.
-func (s *PostStorage) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM posts WHERE id = $1;`
+// Purge permanently deletes posts deleted before the given time, with their comments,
+// returns number of purged posts.
+func (s *PostStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM posts WHERE deleted_at < $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	_, err := s.db.ExecContext(ctx, query, id)
+	result, err := s.db.ExecContext(ctx, query, deletedBefore)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return result.RowsAffected()
 }
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+)
+
+type IReactions interface {
+	Set(ctx context.Context, postID, userID int64, kind string) error
+	Delete(ctx context.Context, postID, userID int64) error
+}
+
+type ReactionStorage struct {
+	db *sql.DB
+}
+
+// Set reacts to the post, replacing the previous reaction of the user.
+// Returns ErrNotFound if the post does not exist.
+func (s *ReactionStorage) Set(ctx context.Context, postID, userID int64, kind string) error {
+	query := `
+		INSERT INTO post_reactions (post_id, user_id, kind) VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, user_id) DO UPDATE SET kind = EXCLUDED.kind, created_at = now()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID, kind)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the reaction of the user, returns ErrNotFound if there was none.
+func (s *ReactionStorage) Delete(ctx context.Context, postID, userID int64) error {
+	query := `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
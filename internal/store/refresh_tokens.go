@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+type IRefreshTokens interface {
+	Rotate(ctx context.Context, plainToken, nextPlainToken string, expiry time.Duration) (*RefreshToken, error)
+}
+
+// RefreshToken model, only the SHA-256 hash of the opaque token is stored.
+// Tokens issued from the same login share the same FamilyID, which is the Session ID.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	Expiry    time.Time  `json:"expiry"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RefreshTokenStorage struct {
+	db *sql.DB
+}
+
+// Rotate marks the given refresh token as used and issues the next token of the same family.
+// Reusing an already used token revokes the whole family and its session,
+// then returns the reused token together with ErrTokenReused.
+// Unknown, expired or revoked tokens return ErrNotFound.
+func (s *RefreshTokenStorage) Rotate(ctx context.Context, plainToken, nextPlainToken string, expiry time.Duration) (*RefreshToken, error) {
+	var (
+		current *RefreshToken
+		next    *RefreshToken
+		reused  bool
+	)
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Get current token and lock the row
+		var err error
+		current, err = s.getByToken(ctx, tx, plainToken)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil || current.Expiry.Before(time.Now()) {
+			return ErrNotFound
+		}
+
+		// 2. Token was already rotated, someone replays it => revoke the family
+		if current.UsedAt != nil {
+			reused = true
+			return revokeSession(ctx, tx, current.FamilyID)
+		}
+
+		// 3. Mark token as used
+		if err = s.markUsed(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		// 4. Issue next token of the family
+		next = &RefreshToken{
+			UserID:   current.UserID,
+			FamilyID: current.FamilyID,
+			Expiry:   time.Now().Add(expiry),
+		}
+
+		if err = createRefreshToken(ctx, tx, next, nextPlainToken); err != nil {
+			return err
+		}
+
+		// 5. Keep session alive as long as its refresh token
+		return s.touchSession(ctx, tx, next.FamilyID, next.Expiry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return current, ErrTokenReused
+	}
+
+	return next, nil
+}
+
+// createRefreshToken stores hash of the plain refresh token, scan return data into RefreshToken instance.
+func createRefreshToken(ctx context.Context, tx *sql.Tx, token *RefreshToken, plainToken string) error {
+	query := `
+	INSERT INTO refresh_tokens (user_id, family_id, token, expiry)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.FamilyID,
+		hashToken(plainToken),
+		token.Expiry,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *RefreshTokenStorage) getByToken(ctx context.Context, tx *sql.Tx, plainToken string) (*RefreshToken, error) {
+	query := `
+	SELECT id, user_id, family_id, expiry, used_at, revoked_at, created_at
+	FROM refresh_tokens
+	WHERE token = $1
+	FOR UPDATE
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var token RefreshToken
+	err := tx.QueryRowContext(ctx, query, hashToken(plainToken)).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.Expiry,
+		&token.UsedAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
+func (s *RefreshTokenStorage) markUsed(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *RefreshTokenStorage) touchSession(ctx context.Context, tx *sql.Tx, sessionID string, expiry time.Time) error {
+	query := `UPDATE sessions SET last_used_at = now(), expiry = $2 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, sessionID, expiry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// hashToken hashes plain token with SHA-256 for storing and comparing.
+func hashToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+)
+
+const (
+	RestrictionBlock = "block"
+	RestrictionMute  = "mute"
+)
+
+type IRestrictions interface {
+	Block(ctx context.Context, userID, targetID int64) error
+	Unblock(ctx context.Context, userID, targetID int64) error
+	Mute(ctx context.Context, userID, targetID int64) error
+	Unmute(ctx context.Context, userID, targetID int64) error
+	IsBlocked(ctx context.Context, userID, otherID int64) (bool, error)
+}
+
+type RestrictionStorage struct {
+	db *sql.DB
+}
+
+// Block blocks targetID and removes follows and follow requests between
+// both users in both directions.
+func (s *RestrictionStorage) Block(ctx context.Context, userID, targetID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// Lock both users, so no follow between them can be created concurrently
+		users, err := lockUsers(ctx, tx, userID, targetID)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := users[targetID]; !ok {
+			return ErrNotFound
+		}
+
+		if err = s.create(ctx, tx, userID, targetID, RestrictionBlock); err != nil {
+			return err
+		}
+
+		return s.deleteFollows(ctx, tx, userID, targetID)
+	})
+}
+
+func (s *RestrictionStorage) Unblock(ctx context.Context, userID, targetID int64) error {
+	return s.delete(ctx, userID, targetID, RestrictionBlock)
+}
+
+// Mute hides posts of targetID from the feed of userID.
+func (s *RestrictionStorage) Mute(ctx context.Context, userID, targetID int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, userID, targetID, RestrictionMute)
+	})
+}
+
+func (s *RestrictionStorage) Unmute(ctx context.Context, userID, targetID int64) error {
+	return s.delete(ctx, userID, targetID, RestrictionMute)
+}
+
+// IsBlocked reports whether one of the users blocked the other.
+func (s *RestrictionStorage) IsBlocked(ctx context.Context, userID, otherID int64) (bool, error) {
+	query := `SELECT ` + blockedBetween("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// create is idempotent, restricting a user twice is not an error.
+func (s *RestrictionStorage) create(ctx context.Context, tx *sql.Tx, userID, targetID int64, kind string) error {
+	query := `
+		INSERT INTO user_restrictions (user_id, target_id, kind) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID, targetID, kind)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// delete returns ErrNotFound if the user was not restricted.
+func (s *RestrictionStorage) delete(ctx context.Context, userID, targetID int64, kind string) error {
+	query := `DELETE FROM user_restrictions WHERE user_id = $1 AND target_id = $2 AND kind = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, userID, targetID, kind)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *RestrictionStorage) deleteFollows(ctx context.Context, tx *sql.Tx, userID, otherID int64) error {
+	query := `
+		WITH f AS (
+			DELETE FROM followers
+			WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+		)
+		DELETE FROM follow_requests
+		WHERE (user_id = $1 AND requester_id = $2) OR (user_id = $2 AND requester_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blockedBetween returns the SQL condition matching users a and b if one blocked the other.
+func blockedBetween(a, b string) string {
+	return `EXISTS (SELECT 1 FROM user_restrictions br
+		WHERE br.kind = 'block' AND ((br.user_id = ` + a + ` AND br.target_id = ` + b + `)
+			OR (br.user_id = ` + b + ` AND br.target_id = ` + a + `)))`
+}
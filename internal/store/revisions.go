@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+// PostRevision is a past version of a post.
+type PostRevision struct {
+	PostID  int64    `json:"post_id"`
+	Version int      `json:"version"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	// CreatedAt is when the version was saved
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetRevisions lists past versions of the post, latest first.
+func (s *PostStorage) GetRevisions(ctx context.Context, postID int64, q PaginatedRevisionQuery) ([]PostRevision, Page, error) {
+	order, cmp := keysetOrder("desc", q.Cursor)
+
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1
+		  AND ($2::timestamptz IS NULL OR (created_at, version) ` + cmp + ` ($2, $3))
+		ORDER BY created_at ` + order + `, version ` + order + `
+		LIMIT $4
+	`
+
+	after, afterVersion := keysetArgs(q.Cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	// Fetch one more revision to know whether there is another page
+	rows, err := s.db.QueryContext(ctx, query, postID, after, afterVersion, q.Limit+1)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	var revisions []PostRevision
+	for rows.Next() {
+		var rev PostRevision
+		err = rows.Scan(&rev.PostID, &rev.Version, &rev.Title, &rev.Content, pq.Array(&rev.Tags), &rev.CreatedAt)
+		if err != nil {
+			return nil, Page{}, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	revisions, page := paginate(revisions, q.Limit, q.Cursor, func(rev PostRevision) Cursor {
+		return Cursor{CreatedAt: rev.CreatedAt, ID: int64(rev.Version)}
+	})
+
+	return revisions, page, nil
+}
+
+// GetRevision gets a past version of the post, or ErrNotFound if there is no such version.
+func (s *PostStorage) GetRevision(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	query := `
+		SELECT post_id, version, title, content, tags, created_at
+		FROM post_revisions
+		WHERE post_id = $1 AND version = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var rev PostRevision
+	err := s.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&rev.PostID,
+		&rev.Version,
+		&rev.Title,
+		&rev.Content,
+		pq.Array(&rev.Tags),
+		&rev.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &rev, nil
+}
+
+// createRevision saves the given version of the post before it is replaced.
+// Returns ErrNotFound if the post is no longer at this version, including when
+// a concurrent update saved the same revision first.
+func (s *PostStorage) createRevision(ctx context.Context, tx *sql.Tx, postID int64, version int) error {
+	query := `
+		INSERT INTO post_revisions (post_id, version, title, content, tags, created_at)
+		SELECT id, version, title, content, tags, COALESCE(updated_at, created_at, now())
+		FROM posts
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := tx.ExecContext(ctx, query, postID, version)
+	if err != nil {
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
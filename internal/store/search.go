@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"strings"
+	"time"
+)
+
+const (
+	SearchTypePost    = "post"
+	SearchTypeComment = "comment"
+	SearchTypeUser    = "user"
+)
+
+// Snippets are highlighted with control characters, replaced by <mark> tags
+// once the rest of the snippet has been HTML escaped.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+type ISearch interface {
+	Search(ctx context.Context, viewerID int64, q PaginatedSearchQuery) ([]SearchResult, error)
+}
+
+// SearchResult is a post, comment or user matching a search query.
+// Snippet is HTML escaped, with matched words wrapped in <mark> tags.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	PostID    *int64    `json:"post_id,omitempty"`
+	Title     string    `json:"title"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchStorage struct {
+	db *sql.DB
+}
+
+// Search ranks posts by weighted title, tags and content, and comments by content,
+// using full-text search with websearch syntax ("quoted phrase", -excluded, or).
+// Trigram similarity of titles, comments and usernames tolerates typos.
+// Posts and comments of private accounts are only visible to their followers,
+// unpublished and deleted posts and deleted users are skipped.
+func (s *SearchStorage) Search(ctx context.Context, viewerID int64, q PaginatedSearchQuery) ([]SearchResult, error) {
+	// Snippets are only highlighted for the page of results, after ranking
+	query := `
+	WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query)
+	SELECT page.type,
+	       page.id,
+	       CASE page.type WHEN 'post' THEN p.id WHEN 'comment' THEN c.post_id END AS post_id,
+	       CASE page.type WHEN 'post' THEN p.title WHEN 'user' THEN u.username ELSE '' END AS title,
+	       CASE page.type
+	           WHEN 'post' THEN ts_headline('simple', p.content, q.query, $5)
+	           WHEN 'comment' THEN ts_headline('simple', c.content, q.query, $5)
+	           ELSE u.username
+	       END AS snippet,
+	       page.rank,
+	       page.created_at
+	FROM (
+		SELECT type, id, rank, created_at
+		FROM (
+			SELECT 'post' AS type, p.id,
+			       ts_rank(p.search_vector, q.query) + similarity(p.title, $1) AS rank,
+			       p.created_at
+			FROM posts p, q
+			WHERE $2 IN ('all', 'post') AND (p.search_vector @@ q.query OR p.title % $1)
+			  AND p.status = 'published' AND p.deleted_at IS NULL AND ` + authorNotDeleted("p.user_id") + `
+			  AND ` + visibleTo("p.user_id", "$6") + `
+
+			UNION ALL
+
+			SELECT 'comment', c.id,
+			       ts_rank(c.search_vector, q.query) + similarity(c.content, $1),
+			       c.created_at
+			FROM comments c
+			JOIN posts cp ON cp.id = c.post_id, q
+			WHERE $2 IN ('all', 'comment') AND (c.search_vector @@ q.query OR c.content % $1)
+			  AND cp.status = 'published' AND cp.deleted_at IS NULL AND ` + authorNotDeleted("cp.user_id") + ` AND ` + authorNotDeleted("c.user_id") + `
+			  AND ` + visibleTo("cp.user_id", "$6") + `
+
+			UNION ALL
+
+			SELECT 'user', u.id, similarity(u.username, $1), u.created_at
+			FROM users u
+			WHERE $2 IN ('all', 'user') AND u.is_active AND u.deleted_at IS NULL AND u.username % $1
+		) results
+		ORDER BY rank DESC, created_at DESC
+		LIMIT $3 OFFSET $4
+	) page
+	CROSS JOIN q
+	LEFT JOIN posts p ON page.type = 'post' AND p.id = page.id
+	LEFT JOIN comments c ON page.type = 'comment' AND c.id = page.id
+	LEFT JOIN users u ON page.type = 'user' AND u.id = page.id
+	ORDER BY page.rank DESC, page.created_at DESC
+`
+
+	headlineOptions := "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxWords=35, MinWords=15"
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, q.Query, q.Type, q.Limit, q.Offset, headlineOptions, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err = rows.Scan(
+			&r.Type,
+			&r.ID,
+			&r.PostID,
+			&r.Title,
+			&r.Snippet,
+			&r.Rank,
+			&r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		r.Snippet = highlight(r.Snippet)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// highlight escapes the snippet and turns highlight markers into <mark> tags.
+func highlight(snippet string) string {
+	return strings.NewReplacer(
+		highlightStart, "<mark>",
+		highlightStop, "</mark>",
+	).Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type ISessions interface {
+	Create(ctx context.Context, session *Session, plainRefreshToken string) error
+	GetByUserID(ctx context.Context, userID int64) ([]Session, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	GetRevokedSince(ctx context.Context, since time.Time) ([]Session, error)
+	Revoke(ctx context.Context, userID int64, id string) error
+	RevokeAll(ctx context.Context, userID int64) ([]string, error)
+}
+
+// Session model, its ID is used as the `jti` claim of every access token
+// and as the family of every refresh token issued for the session.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	Expiry     time.Time  `json:"expiry"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type SessionStorage struct {
+	db *sql.DB
+}
+
+// Create creates a session together with its first refresh token.
+func (s *SessionStorage) Create(ctx context.Context, session *Session, plainRefreshToken string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Create a session
+		if err := s.create(ctx, tx, session); err != nil {
+			return err
+		}
+
+		// 2. Create first refresh token of the session
+		refreshToken := &RefreshToken{
+			UserID:   session.UserID,
+			FamilyID: session.ID,
+			Expiry:   session.Expiry,
+		}
+
+		return createRefreshToken(ctx, tx, refreshToken, plainRefreshToken)
+	})
+}
+
+// GetByUserID gets active sessions of the user, most recently used first.
+func (s *SessionStorage) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	query := `
+	SELECT id, user_id, user_agent, ip_address, expiry, revoked_at, last_used_at, created_at
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expiry > $2
+	ORDER BY last_used_at DESC
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err = rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.Expiry,
+			&session.RevokedAt,
+			&session.LastUsedAt,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// IsRevoked reports whether the session has been revoked,
+// unknown sessions are considered as revoked.
+func (s *SessionStorage) IsRevoked(ctx context.Context, id string) (bool, error) {
+	query := `SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&revoked)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return true, nil
+		default:
+			return false, err
+		}
+	}
+
+	return revoked, nil
+}
+
+// GetRevokedSince gets sessions revoked after since, oldest revocation first.
+func (s *SessionStorage) GetRevokedSince(ctx context.Context, since time.Time) ([]Session, error) {
+	query := `
+	SELECT id, user_id, user_agent, ip_address, expiry, revoked_at, last_used_at, created_at
+	FROM sessions
+	WHERE revoked_at > $1
+	ORDER BY revoked_at
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		if err = rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.Expiry,
+			&session.RevokedAt,
+			&session.LastUsedAt,
+			&session.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Revoke revokes a session of the user and its refresh tokens.
+// If the session does not belong to the user, the function will return ErrNotFound.
+func (s *SessionStorage) Revoke(ctx context.Context, userID int64, id string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Check the session belongs to user
+		if err := s.getActiveForUpdate(ctx, tx, userID, id); err != nil {
+			return err
+		}
+
+		// 2. Revoke the session and its refresh tokens
+		return revokeSession(ctx, tx, id)
+	})
+}
+
+// RevokeAll revokes every active session of the user, returns revoked session IDs.
+func (s *SessionStorage) RevokeAll(ctx context.Context, userID int64) ([]string, error) {
+	var ids []string
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var err error
+		ids, err = revokeUserSessions(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (s *SessionStorage) create(ctx context.Context, tx *sql.Tx, session *Session) error {
+	query := `
+	INSERT INTO sessions (id, user_id, user_agent, ip_address, expiry)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING last_used_at, created_at
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IPAddress,
+		session.Expiry,
+	).Scan(&session.LastUsedAt, &session.CreatedAt)
+}
+
+func (s *SessionStorage) getActiveForUpdate(ctx context.Context, tx *sql.Tx, userID int64, id string) error {
+	query := `
+	SELECT id FROM sessions
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	FOR UPDATE
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := tx.QueryRowContext(ctx, query, id, userID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeSession revokes a session and every refresh token of its family.
+func revokeSession(ctx context.Context, tx *sql.Tx, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// revokeUserSessions revokes every active session of the user and their refresh tokens,
+// returns revoked session IDs.
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	query := `
+	UPDATE sessions SET revoked_at = now()
+	WHERE user_id = $1 AND revoked_at IS NULL
+	RETURNING id
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
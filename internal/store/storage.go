@@ -11,24 +11,46 @@ var (
 	ErrNotFound          = errors.New("resource not found")
 	ErrConflict          = errors.New("resource already exists")
 	ErrFollowSelf        = errors.New("cannot follow yourself")
+	ErrAccountLocked     = errors.New("account is temporarily locked due to too many failed login attempts")
+	ErrTokenReused       = errors.New("refresh token has already been used")
+	ErrTooManyRequests   = errors.New("too many requests, please try again later")
+	ErrBlocked           = errors.New("user is blocked")
 	QueryTimeOutDuration = 5 * time.Second
 )
 
 type Storage struct {
-	Posts     IPosts
-	Users     IUsers
-	Followers IFollower
-	Comments  IComments
-	Roles     IRoles
+	Posts         IPosts
+	Users         IUsers
+	Followers     IFollower
+	Comments      IComments
+	Roles         IRoles
+	LoginAttempts ILoginAttempts
+	RefreshTokens IRefreshTokens
+	Sessions      ISessions
+	Emails        IEmails
+	Search        ISearch
+	Restrictions  IRestrictions
+	Reactions     IReactions
+	Bookmarks     IBookmarks
+	Attachments   IAttachments
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:     &PostStorage{db: db},
-		Users:     &UserStorage{db: db},
-		Followers: &FollowerStorage{db: db},
-		Comments:  &CommentStorage{db: db},
-		Roles:     &RoleStorage{db: db},
+		Posts:         &PostStorage{db: db},
+		Users:         &UserStorage{db: db},
+		Followers:     &FollowerStorage{db: db},
+		Comments:      &CommentStorage{db: db},
+		Roles:         &RoleStorage{db: db},
+		LoginAttempts: &LoginAttemptStorage{db: db},
+		RefreshTokens: &RefreshTokenStorage{db: db},
+		Sessions:      &SessionStorage{db: db},
+		Emails:        &EmailStorage{db: db},
+		Search:        &SearchStorage{db: db},
+		Restrictions:  &RestrictionStorage{db: db},
+		Reactions:     &ReactionStorage{db: db},
+		Bookmarks:     &BookmarkStorage{db: db},
+		Attachments:   &AttachmentStorage{db: db},
 	}
 }
 
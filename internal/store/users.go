@@ -15,10 +15,19 @@ import (
 type IUsers interface {
 	GetByID(ctx context.Context, id int64) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetProfile(ctx context.Context, id, viewerID int64) (*UserProfile, error)
+	UpdateSettings(ctx context.Context, user *User) error
 	Create(ctx context.Context, tx *sql.Tx, user *User) error
-	CreateAndInvite(ctx context.Context, user *User, token string, expiryDuration time.Duration) error
+	CreateAndInvite(ctx context.Context, user *User, token string, expiryDuration time.Duration, invitation EmailFunc) error
 	Activate(ctx context.Context, token string) error
-	Delete(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64) ([]string, error)
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	ResendInvitation(ctx context.Context, email, token string, expiryDuration, cooldown time.Duration, invitation EmailFunc) (*User, error)
+	DeleteExpiredInvitations(ctx context.Context) (int64, error)
+	DeleteUnactivated(ctx context.Context, olderThan time.Duration) (int64, error)
+	CreatePasswordReset(ctx context.Context, userID int64, token string, expiryDuration time.Duration) error
+	ResetPassword(ctx context.Context, token, newPassword string) ([]string, error)
 }
 
 type User struct {
@@ -29,8 +38,34 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	IsActive  bool      `json:"is_active"`
+	Locale    string    `json:"locale"`
+	IsPrivate bool      `json:"is_private"`
 	RoleID    int64     `json:"role_id"`
 	Role      Role      `json:"role,omitempty"`
+
+	FailedLoginAttempts int        `json:"-"`
+	LockedUntil         *time.Time `json:"-"`
+}
+
+// IsLocked reports whether the account is temporarily locked
+// because of too many failed login attempts.
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// UserProfile is the public view of a user,
+// IsFollowing reports whether the viewer follows the user,
+// FollowRequested whether the viewer's follow request is pending.
+type UserProfile struct {
+	ID              int64     `json:"id"`
+	Username        string    `json:"username"`
+	CreatedAt       time.Time `json:"created_at"`
+	IsPrivate       bool      `json:"is_private"`
+	FollowersCount  int64     `json:"followers_count"`
+	FollowingCount  int64     `json:"following_count"`
+	PostsCount      int64     `json:"posts_count"`
+	IsFollowing     bool      `json:"is_following"`
+	FollowRequested bool      `json:"follow_requested"`
 }
 
 type password struct {
@@ -44,8 +79,8 @@ type UserStorage struct {
 
 func (s *UserStorage) Create(ctx context.Context, tx *sql.Tx, users *User) error {
 	query := `
-	INSERT INTO users (username, email, password, role_id)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO users (username, email, password, locale, role_id)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id, created_at, updated_at
 `
 	err := tx.QueryRowContext(
@@ -54,6 +89,7 @@ func (s *UserStorage) Create(ctx context.Context, tx *sql.Tx, users *User) error
 		users.Username,
 		users.Email,
 		users.Password.hash,
+		users.Locale,
 		users.RoleID,
 	).Scan(
 		&users.ID,
@@ -77,7 +113,7 @@ func (s *UserStorage) Create(ctx context.Context, tx *sql.Tx, users *User) error
 	return nil
 }
 
-func (s *UserStorage) CreateAndInvite(ctx context.Context, user *User, token string, expiry time.Duration) error {
+func (s *UserStorage) CreateAndInvite(ctx context.Context, user *User, token string, expiry time.Duration, invitation EmailFunc) error {
 	return withTx(ctx, s.db, func(tx *sql.Tx) error {
 		// 1. Create a user
 		if err := s.Create(ctx, tx, user); err != nil {
@@ -89,8 +125,118 @@ func (s *UserStorage) CreateAndInvite(ctx context.Context, user *User, token str
 			return err
 		}
 
-		return nil
+		// 3. Enqueue the activation email, so it is sent if and only if the user is created
+		email, err := invitation(user)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, email)
+	})
+}
+
+// ResendInvitation rotates invitation token of an inactive user found by email,
+// and enqueues the invitation email in the same transaction.
+// It returns ErrNotFound if there is no inactive user with the email,
+// or ErrTooManyRequests if the last invitation was sent within cooldown duration.
+func (s *UserStorage) ResendInvitation(ctx context.Context, email, token string, expiry, cooldown time.Duration, invitation EmailFunc) (*User, error) {
+	var user *User
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Get inactive user and lock the row
+		var err error
+		user, err = s.getInactiveByEmail(ctx, tx, email)
+		if err != nil {
+			return err
+		}
+
+		// 2. Check the last invitation is old enough
+		lastInvitedAt, err := s.getLastInvitedAt(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if lastInvitedAt != nil && time.Since(*lastInvitedAt) < cooldown {
+			return ErrTooManyRequests
+		}
+
+		// 3. Replace old invitations with the new token
+		if err = s.deleteUserInvitation(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		if err = s.createUserInvitation(ctx, tx, user, token, expiry); err != nil {
+			return err
+		}
+
+		// 4. Enqueue the activation email of the new token
+		activation, err := invitation(user)
+		if err != nil {
+			return err
+		}
+
+		return enqueueEmail(ctx, tx, activation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteExpiredInvitations purges expired invitations, returns number of deleted rows.
+func (s *UserStorage) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	query := `DELETE FROM user_invitation WHERE expiry < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteUnactivated deletes users who never activated their account
+// within olderThan duration since their latest invitation, returns number of deleted users.
+// A resent invitation restarts the grace period.
+func (s *UserStorage) DeleteUnactivated(ctx context.Context, olderThan time.Duration) (int64, error) {
+	var deleted int64
+
+	invitationsQuery := `
+	DELETE FROM user_invitation ui
+	USING users u
+	WHERE ui.user_id = u.id AND u.is_active = false AND coalesce(u.invited_at, u.created_at) < $1
+`
+	usersQuery := `DELETE FROM users WHERE is_active = false AND coalesce(invited_at, created_at) < $1`
+
+	invitedBefore := time.Now().Add(-olderThan)
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		// 1. Clear their invitations, user_invitation does not cascade
+		if _, err := tx.ExecContext(ctx, invitationsQuery, invitedBefore); err != nil {
+			return err
+		}
+
+		// 2. Delete the users
+		result, err := tx.ExecContext(ctx, usersQuery, invitedBefore)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = result.RowsAffected()
+		return err
 	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
 }
 
 func (s *UserStorage) Activate(ctx context.Context, token string) error {
@@ -116,12 +262,64 @@ func (s *UserStorage) Activate(ctx context.Context, token string) error {
 	})
 }
 
+// CreatePasswordReset replaces pending password reset of the user with the new hashed token.
+func (s *UserStorage) CreatePasswordReset(ctx context.Context, userID int64, token string, expiry time.Duration) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Only the latest token is valid
+		if err := s.deletePasswordReset(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		// 2. insert token with expiry time to password_reset
+		return s.createPasswordReset(ctx, tx, userID, token, expiry)
+	})
+}
+
+// ResetPassword sets new password of the user owning the plain token,
+// then clears the token and revokes every session of the user.
+// It returns revoked session IDs, or ErrNotFound if the token is invalid or expired.
+func (s *UserStorage) ResetPassword(ctx context.Context, token, newPassword string) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		// 1. Get user using token, before spending time on hashing the password
+		userID, err := s.getUserFromPasswordReset(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		// 2. Update password and unlock the account
+		var pw password
+		if err = pw.Set(newPassword); err != nil {
+			return err
+		}
+
+		if err = s.updatePassword(ctx, tx, userID, pw); err != nil {
+			return err
+		}
+
+		// 3. Clear password reset
+		if err = s.deletePasswordReset(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		// 4. Sign out from every device
+		sessionIDs, err = revokeUserSessions(ctx, tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
+
 func (s *UserStorage) GetByID(ctx context.Context, id int64) (*User, error) {
 	query := `
-	SELECT users.id, username, email, created_at, updated_at, is_active, role_id, roles.*
+	SELECT users.id, username, email, created_at, updated_at, is_active, locale, is_private, role_id, roles.*
 	FROM users
 	INNER JOIN roles ON users.role_id = roles.id
-	WHERE users.id = $1 AND is_active=true
+	WHERE users.id = $1 AND is_active=true AND users.deleted_at IS NULL
 `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
@@ -134,6 +332,8 @@ func (s *UserStorage) GetByID(ctx context.Context, id int64) (*User, error) {
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.IsActive,
+		&user.Locale,
+		&user.IsPrivate,
 		&user.RoleID,
 		&user.Role.ID,
 		&user.Role.Name,
@@ -153,11 +353,81 @@ func (s *UserStorage) GetByID(ctx context.Context, id int64) (*User, error) {
 	return &user, nil
 }
 
+// GetProfile gets the profile of an active user seen by viewerID,
+// counters are maintained by database triggers.
+func (s *UserStorage) GetProfile(ctx context.Context, id, viewerID int64) (*UserProfile, error) {
+	query := `
+	SELECT u.id, u.username, u.created_at, u.is_private, u.followers_count, u.following_count, u.posts_count,
+	       EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = $2),
+	       EXISTS (SELECT 1 FROM follow_requests fr WHERE fr.user_id = u.id AND fr.requester_id = $2)
+	FROM users u
+	WHERE u.id = $1 AND u.is_active = true AND u.deleted_at IS NULL
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var p UserProfile
+	err := s.db.QueryRowContext(ctx, query, id, viewerID).Scan(
+		&p.ID,
+		&p.Username,
+		&p.CreatedAt,
+		&p.IsPrivate,
+		&p.FollowersCount,
+		&p.FollowingCount,
+		&p.PostsCount,
+		&p.IsFollowing,
+		&p.FollowRequested,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// UpdateSettings updates locale and privacy of the user. When the account turns public,
+// its pending follow requests are accepted.
+func (s *UserStorage) UpdateSettings(ctx context.Context, user *User) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `
+		UPDATE users SET locale = $2, is_private = $3, updated_at = now()
+		WHERE id = $1
+		RETURNING updated_at
+`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, user.ID, user.Locale, user.IsPrivate).Scan(&user.UpdatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if user.IsPrivate {
+			return nil
+		}
+
+		return s.acceptFollowRequests(ctx, tx, user.ID)
+	})
+}
+
 func (s *UserStorage) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
-	SELECT id, username, email, created_at, updated_at, is_active
+	SELECT id, username, email, password, created_at, updated_at, is_active, locale, failed_login_attempts, locked_until
 	FROM users 
-	WHERE email = $1 AND is_active=true
+	WHERE email = $1 AND is_active=true AND deleted_at IS NULL
 `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -168,9 +438,13 @@ func (s *UserStorage) GetByEmail(ctx context.Context, email string) (*User, erro
 		&u.ID,
 		&u.Username,
 		&u.Email,
+		&u.Password.hash,
 		&u.CreatedAt,
 		&u.UpdatedAt,
 		&u.IsActive,
+		&u.Locale,
+		&u.FailedLoginAttempts,
+		&u.LockedUntil,
 	)
 
 	if err != nil {
@@ -185,49 +459,147 @@ func (s *UserStorage) GetByEmail(ctx context.Context, email string) (*User, erro
 	return &u, nil
 }
 
-func (s *UserStorage) Delete(ctx context.Context, id int64) error {
-	// Delete user and its invitations
-	return withTx(ctx, s.db, func(tx *sql.Tx) error {
-		err := s.deleteUserInvitation(ctx, tx, id)
+// Delete moves the user to the trash and revokes every session of the user,
+// it is purged after the retention period. It returns revoked session IDs,
+// or ErrNotFound if the user does not exist or is already deleted.
+func (s *UserStorage) Delete(ctx context.Context, id int64) ([]string, error) {
+	var sessionIDs []string
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `UPDATE users SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
+
+		result, err := tx.ExecContext(ctx, query, id)
 		if err != nil {
 			return err
 		}
 
-		err = s.delete(ctx, tx, id)
+		rows, err := result.RowsAffected()
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if rows != 1 {
+			return ErrNotFound
+		}
+
+		sessionIDs, err = revokeUserSessions(ctx, tx, id)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
 }
 
-func (p *password) Set(password string) error {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// Restore takes a user out of the trash, returns ErrNotFound if the user is not deleted.
+func (s *UserStorage) Restore(ctx context.Context, id int64) error {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
 
-	p.text = &password
-	p.hash = bytes
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows != 1 {
+		return ErrNotFound
+	}
+
 	return nil
 }
 
-func (s *UserStorage) delete(ctx context.Context, tx *sql.Tx, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
+// Purge permanently deletes users deleted before the given time with their posts,
+// returns number of purged users.
+func (s *UserStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-	defer cancel()
+	postsQuery := `
+	DELETE FROM posts p
+	USING users u
+	WHERE p.user_id = u.id AND u.deleted_at < $1
+`
+	invitationsQuery := `
+	DELETE FROM user_invitation ui
+	USING users u
+	WHERE ui.user_id = u.id AND u.deleted_at < $1
+`
+	usersQuery := `DELETE FROM users WHERE deleted_at < $1`
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+		defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, id)
+		// 1. Delete their posts and invitations, which do not cascade
+		if _, err := tx.ExecContext(ctx, postsQuery, deletedBefore); err != nil {
+			return err
+		}
 
+		if _, err := tx.ExecContext(ctx, invitationsQuery, deletedBefore); err != nil {
+			return err
+		}
+
+		// 2. Delete the users, the rest of their data cascades
+		result, err := tx.ExecContext(ctx, usersQuery, deletedBefore)
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+func (p *password) Set(password string) error {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
 		return err
 	}
 
+	p.text = &password
+	p.hash = bytes
 	return nil
 }
 
+// Matches compares plain text password with the stored bcrypt hash.
+func (p *password) Matches(plainText string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plainText))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// dummyPasswordHash is a bcrypt hash with the cost of Set, of a password nobody knows.
+const dummyPasswordHash = "$2a$14$VSzOW6pwLmHSJNwAYqcNdO/J7HaMzpL5/.TLJjVEBHvJTZcs7zYzO"
+
+// CompareDummyPassword takes as long as Matches, it is called when the user does not exist
+// so response times do not reveal which emails have an account.
+func CompareDummyPassword(plainText string) {
+	_ = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(plainText))
+}
+
 func (s *UserStorage) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
 	var user User
 
@@ -271,6 +643,27 @@ func (s *UserStorage) update(ctx context.Context, tx *sql.Tx, user *User) error
 	return nil
 }
 
+func (s *UserStorage) acceptFollowRequests(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+	WITH accepted AS (
+		DELETE FROM follow_requests WHERE user_id = $1 RETURNING user_id, requester_id
+	)
+	INSERT INTO followers (user_id, follower_id)
+	SELECT user_id, requester_id FROM accepted
+	ON CONFLICT DO NOTHING
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStorage) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM user_invitation WHERE user_id = $1`
 
@@ -285,8 +678,11 @@ func (s *UserStorage) deleteUserInvitation(ctx context.Context, tx *sql.Tx, user
 	return nil
 }
 
+// createUserInvitation inserts the invitation token and records when the user was invited,
+// which starts the grace period of the janitor.
 func (s *UserStorage) createUserInvitation(ctx context.Context, tx *sql.Tx, user *User, token string, expiry time.Duration) error {
 	query := `INSERT INTO user_invitation (user_id, token, expiry) VALUES ($1, $2, $3)`
+	invitedQuery := `UPDATE users SET invited_at = now() WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
@@ -296,5 +692,134 @@ func (s *UserStorage) createUserInvitation(ctx context.Context, tx *sql.Tx, user
 		return err
 	}
 
+	if _, err = tx.ExecContext(ctx, invitedQuery, user.ID); err != nil {
+		return err
+	}
+
 	return nil
 }
+
+func (s *UserStorage) getInactiveByEmail(ctx context.Context, tx *sql.Tx, email string) (*User, error) {
+	query := `
+	SELECT id, username, email, created_at, updated_at, is_active, locale
+	FROM users
+	WHERE email = $1 AND is_active = false
+	FOR UPDATE
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var u User
+	err := tx.QueryRowContext(ctx, query, email).Scan(
+		&u.ID,
+		&u.Username,
+		&u.Email,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+		&u.IsActive,
+		&u.Locale,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &u, nil
+}
+
+func (s *UserStorage) getLastInvitedAt(ctx context.Context, tx *sql.Tx, userID int64) (*time.Time, error) {
+	query := `SELECT MAX(created_at) FROM user_invitation WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var lastInvitedAt *time.Time
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(&lastInvitedAt); err != nil {
+		return nil, err
+	}
+
+	return lastInvitedAt, nil
+}
+
+func (s *UserStorage) createPasswordReset(ctx context.Context, tx *sql.Tx, userID int64, token string, expiry time.Duration) error {
+	query := `INSERT INTO password_reset (user_id, token, expiry) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID, token, time.Now().Add(expiry))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStorage) getUserFromPasswordReset(ctx context.Context, tx *sql.Tx, token string) (int64, error) {
+	query := `
+	SELECT u.id
+	FROM users u
+	INNER JOIN password_reset pr ON u.id = pr.user_id
+	WHERE pr.token = $1 AND pr.expiry > $2 AND u.is_active = true AND u.deleted_at IS NULL
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var userID int64
+	err := tx.QueryRowContext(ctx, query, hashToken(token), time.Now()).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (s *UserStorage) updatePassword(ctx context.Context, tx *sql.Tx, userID int64, pw password) error {
+	query := `
+	UPDATE users
+	SET password = $1, failed_login_attempts = 0, locked_until = NULL, updated_at = now()
+	WHERE id = $2
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, pw.hash, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *UserStorage) deletePasswordReset(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `DELETE FROM password_reset WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// authorNotDeleted returns the SQL condition matching rows whose author column
+// references a user who is not deleted.
+func authorNotDeleted(author string) string {
+	return `NOT EXISTS (SELECT 1 FROM users du WHERE du.id = ` + author + ` AND du.deleted_at IS NOT NULL)`
+}